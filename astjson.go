@@ -0,0 +1,656 @@
+package formula
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ASTSchemaVersion is the version of the JSON written by MarshalAST. It changes when the schema
+// changes in a way older readers can't load, UnmarshalAST only loads the versions it knows.
+const ASTSchemaVersion = 1
+
+// MarshalAST encodes a node and its children as JSON:
+//
+//	{"version":1,"root":{"kind":"BinaryExpression","pos":0,"end":5,"left":{...},"operator":{"kind":"Token","token":"Plus",...},"right":{...}}}
+//
+// Syntax kinds and token flags are written by name, so the JSON stays valid when the constants
+// are renumbered. A *SourceCode keeps its text and statements, its diagnostics, comments and tokens
+// are not written.
+func MarshalAST(node Node) ([]byte, error) {
+	root, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&astDocument{Version: ASTSchemaVersion, Root: root})
+}
+
+// UnmarshalAST decodes the JSON written by MarshalAST. The nodes get new IDs in source order and
+// their parent links are set.
+func UnmarshalAST(data []byte) (Node, error) {
+	var document astDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Version < 1 || document.Version > ASTSchemaVersion {
+		return nil, fmt.Errorf("unsupported ast schema version %d", document.Version)
+	}
+	if document.Root == nil {
+		return nil, fmt.Errorf("ast has no root node")
+	}
+	var d astDecoder
+	return d.decodeNode(document.Root, nil)
+}
+
+type astDocument struct {
+	Version int      `json:"version"`
+	Root    *astNode `json:"root"`
+}
+
+// astNode holds the fields of all node kinds, a node only uses the fields of its kind.
+type astNode struct {
+	Kind string `json:"kind"`
+	Pos  int    `json:"pos"`
+	End  int    `json:"end"`
+
+	Token    string   `json:"token,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Head     string   `json:"head,omitempty"`
+	Literal  string   `json:"literal,omitempty"`
+	Assert   bool     `json:"assert,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+	Strict   bool     `json:"strict,omitempty"`
+
+	Name        *astNode `json:"name,omitempty"`
+	Initializer *astNode `json:"initializer,omitempty"`
+	Expression  *astNode `json:"expression,omitempty"`
+	Left        *astNode `json:"left,omitempty"`
+	Operator    *astNode `json:"operator,omitempty"`
+	Operand     *astNode `json:"operand,omitempty"`
+	Right       *astNode `json:"right,omitempty"`
+	Condition   *astNode `json:"condition,omitempty"`
+	QuestionTok *astNode `json:"questionToken,omitempty"`
+	WhenTrue    *astNode `json:"whenTrue,omitempty"`
+	ColonTok    *astNode `json:"colonToken,omitempty"`
+	WhenFalse   *astNode `json:"whenFalse,omitempty"`
+	Argument    *astNode `json:"argument,omitempty"`
+	Body        *astNode `json:"body,omitempty"`
+	Result      *astNode `json:"result,omitempty"`
+	EndOfFile   *astNode `json:"endOfFileToken,omitempty"`
+
+	Statements *astList `json:"statements,omitempty"`
+	Elements   *astList `json:"elements,omitempty"`
+	Properties *astList `json:"properties,omitempty"`
+	Spans      *astList `json:"spans,omitempty"`
+	Parameters *astList `json:"parameters,omitempty"`
+	Arguments  *astList `json:"arguments,omitempty"`
+	Arms       *astList `json:"arms,omitempty"`
+	Patterns   *astList `json:"patterns,omitempty"`
+}
+
+type astList struct {
+	Pos   int        `json:"pos"`
+	End   int        `json:"end"`
+	Nodes []*astNode `json:"nodes"`
+}
+
+func encodeNode(node Node) (*astNode, error) {
+	if isNilNode(node) {
+		return nil, nil
+	}
+	var j = &astNode{Pos: node.Pos(), End: node.End()}
+	var err error
+	// encode a child, the first error is kept
+	var child = func(node Node) *astNode {
+		if err != nil {
+			return nil
+		}
+		var c *astNode
+		c, err = encodeNode(node)
+		return c
+	}
+	switch n := node.(type) {
+	case *SourceCode:
+		j.Kind = "SourceCode"
+		j.Text = string(n.Text)
+		j.Statements = encodeList(n.Statements, &err)
+		// The expression of a program with statements is made from them
+		if n.Statements.Len() == 0 {
+			j.Expression = child(n.Expression)
+		}
+		j.EndOfFile = child(n.EndOfFileToken)
+	case *TokenNode:
+		j.Kind = "Token"
+		j.Token = syntaxKindName(n.Token)
+	case *VariableStatement:
+		j.Kind = "VariableStatement"
+		j.Name = child(n.Name)
+		j.Initializer = child(n.Initializer)
+	case *ExpressionStatement:
+		j.Kind = "ExpressionStatement"
+		j.Expression = child(n.Expression)
+	case *Identifier:
+		j.Kind = "Identifier"
+		j.Value = n.Value
+		if n.OriginalToken != SK_Unknown {
+			j.Token = syntaxKindName(n.OriginalToken)
+		}
+	case *PrefixUnaryExpression:
+		j.Kind = "PrefixUnaryExpression"
+		j.Operator = child(n.Operator)
+		j.Operand = child(n.Operand)
+	case *TypeOfExpression:
+		j.Kind = "TypeOfExpression"
+		j.Expression = child(n.Expression)
+	case *BinaryExpression:
+		j.Kind = "BinaryExpression"
+		j.Left = child(n.Left)
+		j.Operator = child(n.Operator)
+		j.Right = child(n.Right)
+	case *ConditionalExpression:
+		j.Kind = "ConditionalExpression"
+		j.Condition = child(n.Condition)
+		j.QuestionTok = child(n.QuestionTok)
+		j.WhenTrue = child(n.WhenTrue)
+		j.ColonTok = child(n.ColonTok)
+		j.WhenFalse = child(n.WhenFalse)
+	case *ArrayLiteralExpression:
+		j.Kind = "ArrayLiteralExpression"
+		j.Elements = encodeList(n.Elements, &err)
+	case *ObjectLiteralExpression:
+		j.Kind = "ObjectLiteralExpression"
+		j.Properties = encodeList(n.Properties, &err)
+	case *PropertyAssignment:
+		j.Kind = "PropertyAssignment"
+		j.Name = child(n.Name)
+		j.Initializer = child(n.Initializer)
+	case *ShorthandPropertyAssignment:
+		j.Kind = "ShorthandPropertyAssignment"
+		j.Name = child(n.Name)
+	case *SpreadAssignment:
+		j.Kind = "SpreadAssignment"
+		j.Expression = child(n.Expression)
+	case *TemplateExpression:
+		j.Kind = "TemplateExpression"
+		j.Head = n.Head
+		j.Spans = encodeList(n.Spans, &err)
+	case *TemplateSpan:
+		j.Kind = "TemplateSpan"
+		j.Expression = child(n.Expression)
+		j.Literal = n.Literal
+	case *ParenthesizedExpression:
+		j.Kind = "ParenthesizedExpression"
+		j.Expression = child(n.Expression)
+	case *LiteralExpression:
+		j.Kind = "LiteralExpression"
+		j.Token = syntaxKindName(n.Token)
+		j.Value = n.Value
+		j.Flags = tokenFlagNames(n.Flags)
+	case *SelectorExpression:
+		j.Kind = "SelectorExpression"
+		j.Expression = child(n.Expression)
+		j.Name = child(n.Name)
+		j.Assert = n.Assert
+		j.Optional = n.Optional
+	case *ElementAccessExpression:
+		j.Kind = "ElementAccessExpression"
+		j.Expression = child(n.Expression)
+		j.Argument = child(n.ArgumentExpression)
+		j.Optional = n.Optional
+	case *ArrowFunction:
+		j.Kind = "ArrowFunction"
+		j.Parameters = encodeList(n.Parameters, &err)
+		j.Body = child(n.Body)
+	case *CallExpression:
+		j.Kind = "CallExpression"
+		j.Expression = child(n.Expression)
+		j.Arguments = encodeList(n.Arguments, &err)
+	case *SpreadElement:
+		j.Kind = "SpreadElement"
+		j.Expression = child(n.Expression)
+	case *MatchExpression:
+		j.Kind = "MatchExpression"
+		j.Expression = child(n.Expression)
+		j.Strict = n.Strict
+		j.Arms = encodeList(n.Arms, &err)
+	case *MatchArm:
+		j.Kind = "MatchArm"
+		j.Patterns = encodeList(n.Patterns, &err)
+		j.Result = child(n.Result)
+	case *BlockExpression:
+		j.Kind = "BlockExpression"
+		j.Statements = encodeList(n.Statements, &err)
+	default:
+		return nil, fmt.Errorf("unknown node type %T", node)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func encodeList[T Node](list *NodeList[T], err *error) *astList {
+	if list == nil || *err != nil {
+		return nil
+	}
+	var j = &astList{Pos: list.Pos(), End: list.End(), Nodes: make([]*astNode, 0, list.Len())}
+	for _, node := range list.Array() {
+		c, e := encodeNode(node)
+		if e != nil {
+			*err = e
+			return nil
+		}
+		j.Nodes = append(j.Nodes, c)
+	}
+	return j
+}
+
+// isNilNode reports whether node is nil or a typed nil pointer, e.g. a nil *Identifier in an Expression.
+func isNilNode(node Node) bool {
+	if node == nil {
+		return true
+	}
+	switch n := node.(type) {
+	case *TokenNode:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	}
+	return false
+}
+
+type astDecoder struct {
+	nextID          int
+	identifierCount int
+}
+
+func (d *astDecoder) decodeNode(j *astNode, parent Node) (Node, error) {
+	if j == nil {
+		return nil, nil
+	}
+	var node Node
+	var err error
+	// decode a child of node, the first error is kept
+	var child = func(c *astNode) Node {
+		if err != nil || c == nil {
+			return nil
+		}
+		var n Node
+		n, err = d.decodeNode(c, node)
+		return n
+	}
+	var expr = func(c *astNode) Expression {
+		var n = child(c)
+		if n == nil {
+			return nil
+		}
+		e, ok := n.(Expression)
+		if !ok && err == nil {
+			err = fmt.Errorf("node kind '%s' is not an expression", c.Kind)
+		}
+		return e
+	}
+	var identifier = func(c *astNode) *Identifier {
+		var n = child(c)
+		if n == nil {
+			return nil
+		}
+		i, ok := n.(*Identifier)
+		if !ok && err == nil {
+			err = fmt.Errorf("node kind '%s' is not an identifier", c.Kind)
+		}
+		return i
+	}
+	var token = func(c *astNode) *TokenNode {
+		var n = child(c)
+		if n == nil {
+			return nil
+		}
+		t, ok := n.(*TokenNode)
+		if !ok && err == nil {
+			err = fmt.Errorf("node kind '%s' is not a token", c.Kind)
+		}
+		return t
+	}
+	// check a child that can't be left out, name is its json key
+	var required = func(c *astNode, name string) *astNode {
+		if c == nil && err == nil {
+			err = fmt.Errorf("%s has no %s", j.Kind, name)
+		}
+		return c
+	}
+	var kind = func(name string) SyntaxKind {
+		k, ok := syntaxKindFromName(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown syntax kind '%s'", name)
+		}
+		return k
+	}
+
+	d.nextID++
+	var id = d.nextID
+	switch j.Kind {
+	case "SourceCode":
+		var n = new(SourceCode)
+		node = n
+		n.Text = []byte(j.Text)
+		n.Statements = decodeList[Statement](d, j.Statements, n, &err)
+		if n.Statements == nil {
+			n.Statements = new(NodeList[Statement])
+		}
+		if n.Statements.Len() == 0 {
+			n.Expression = expr(j.Expression)
+		} else if statement, ok := n.Statements.At(0).(*ExpressionStatement); ok && n.Statements.Len() == 1 {
+			n.Expression = statement.Expression
+		} else {
+			var block = &BlockExpression{Statements: n.Statements}
+			block.SetPos(n.Statements.Pos())
+			block.SetEnd(n.Statements.End())
+			d.nextID++
+			block.SetID(d.nextID)
+			block.SetParent(n)
+			n.Expression = block
+		}
+		n.EndOfFileToken = token(j.EndOfFile)
+		n.IdentifierCount = d.identifierCount
+	case "Token":
+		node = &TokenNode{Token: kind(j.Token)}
+	case "VariableStatement":
+		var n = new(VariableStatement)
+		node = n
+		n.Name = identifier(required(j.Name, "name"))
+		n.Initializer = expr(required(j.Initializer, "initializer"))
+	case "ExpressionStatement":
+		var n = new(ExpressionStatement)
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+	case "Identifier":
+		var n = &Identifier{Value: j.Value}
+		node = n
+		if len(j.Token) > 0 {
+			n.OriginalToken = kind(j.Token)
+		}
+		d.identifierCount++
+	case "PrefixUnaryExpression":
+		var n = new(PrefixUnaryExpression)
+		node = n
+		n.Operator = token(required(j.Operator, "operator"))
+		n.Operand = expr(required(j.Operand, "operand"))
+	case "TypeOfExpression":
+		var n = new(TypeOfExpression)
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+	case "BinaryExpression":
+		var n = new(BinaryExpression)
+		node = n
+		n.Left = expr(required(j.Left, "left"))
+		n.Operator = token(required(j.Operator, "operator"))
+		n.Right = expr(required(j.Right, "right"))
+	case "ConditionalExpression":
+		var n = new(ConditionalExpression)
+		node = n
+		n.Condition = expr(required(j.Condition, "condition"))
+		n.QuestionTok = token(j.QuestionTok)
+		n.WhenTrue = expr(required(j.WhenTrue, "whenTrue"))
+		n.ColonTok = token(j.ColonTok)
+		n.WhenFalse = expr(required(j.WhenFalse, "whenFalse"))
+	case "ArrayLiteralExpression":
+		var n = new(ArrayLiteralExpression)
+		node = n
+		n.Elements = decodeList[Expression](d, j.Elements, n, &err)
+	case "ObjectLiteralExpression":
+		var n = new(ObjectLiteralExpression)
+		node = n
+		n.Properties = decodeList[ObjectLiteralElement](d, j.Properties, n, &err)
+	case "PropertyAssignment":
+		var n = new(PropertyAssignment)
+		node = n
+		n.Name = expr(required(j.Name, "name"))
+		n.Initializer = expr(required(j.Initializer, "initializer"))
+	case "ShorthandPropertyAssignment":
+		var n = new(ShorthandPropertyAssignment)
+		node = n
+		n.Name = identifier(required(j.Name, "name"))
+	case "SpreadAssignment":
+		var n = new(SpreadAssignment)
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+	case "TemplateExpression":
+		var n = &TemplateExpression{Head: j.Head}
+		node = n
+		n.Spans = decodeList[*TemplateSpan](d, j.Spans, n, &err)
+	case "TemplateSpan":
+		var n = &TemplateSpan{Literal: j.Literal}
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+	case "ParenthesizedExpression":
+		var n = new(ParenthesizedExpression)
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+	case "LiteralExpression":
+		var n = &LiteralExpression{Value: j.Value}
+		node = n
+		n.Token = kind(j.Token)
+		flags, flagsErr := tokenFlagsFromNames(j.Flags)
+		if err == nil {
+			err = flagsErr
+		}
+		n.Flags = flags
+	case "SelectorExpression":
+		var n = &SelectorExpression{Assert: j.Assert, Optional: j.Optional}
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+		n.Name = identifier(required(j.Name, "name"))
+	case "ElementAccessExpression":
+		var n = &ElementAccessExpression{Optional: j.Optional}
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+		n.ArgumentExpression = expr(required(j.Argument, "argument"))
+	case "ArrowFunction":
+		var n = new(ArrowFunction)
+		node = n
+		n.Parameters = decodeList[*Identifier](d, j.Parameters, n, &err)
+		n.Body = expr(required(j.Body, "body"))
+	case "CallExpression":
+		var n = new(CallExpression)
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+		n.Arguments = decodeList[Expression](d, j.Arguments, n, &err)
+	case "SpreadElement":
+		var n = new(SpreadElement)
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+	case "MatchExpression":
+		var n = &MatchExpression{Strict: j.Strict}
+		node = n
+		n.Expression = expr(required(j.Expression, "expression"))
+		n.Arms = decodeList[*MatchArm](d, j.Arms, n, &err)
+	case "MatchArm":
+		var n = new(MatchArm)
+		node = n
+		n.Patterns = decodeList[Expression](d, j.Patterns, n, &err)
+		n.Result = expr(required(j.Result, "result"))
+	case "BlockExpression":
+		var n = new(BlockExpression)
+		node = n
+		n.Statements = decodeList[Statement](d, j.Statements, n, &err)
+	default:
+		return nil, fmt.Errorf("unknown node kind '%s'", j.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	node.SetID(id)
+	node.SetParent(parent)
+	node.SetPos(j.Pos)
+	node.SetEnd(j.End)
+	if source, ok := node.(*SourceCode); ok {
+		source.NodeCount = d.nextID
+	}
+	return node, nil
+}
+
+// decodeList decodes the nodes of a list, the nodes must be of type T.
+func decodeList[T Node](d *astDecoder, j *astList, parent Node, err *error) *NodeList[T] {
+	if j == nil || *err != nil {
+		return nil
+	}
+	var list = new(NodeList[T])
+	list.SetPos(j.Pos)
+	list.SetEnd(j.End)
+	for _, c := range j.Nodes {
+		n, e := d.decodeNode(c, parent)
+		if e != nil {
+			*err = e
+			return nil
+		}
+		t, ok := n.(T)
+		if !ok {
+			*err = fmt.Errorf("unexpected node kind '%s' in list", c.Kind)
+			return nil
+		}
+		list.Add(t)
+	}
+	return list
+}
+
+func syntaxKindName(kind SyntaxKind) string {
+	if kind >= 0 && int(kind) < len(syntaxKindNames) && len(syntaxKindNames[kind]) > 0 {
+		return syntaxKindNames[kind]
+	}
+	return syntaxKindNames[SK_Unknown]
+}
+
+func syntaxKindFromName(name string) (SyntaxKind, bool) {
+	for kind, n := range syntaxKindNames {
+		if n == name {
+			return SyntaxKind(kind), true
+		}
+	}
+	return SK_Unknown, false
+}
+
+func tokenFlagNames(flags TokenFlags) []string {
+	var names []string
+	for _, flag := range tokenFlagList {
+		if flags&flag.flag != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+func tokenFlagsFromNames(names []string) (TokenFlags, error) {
+	var flags TokenFlags
+next:
+	for _, name := range names {
+		for _, flag := range tokenFlagList {
+			if flag.name == name {
+				flags |= flag.flag
+				continue next
+			}
+		}
+		return flags, fmt.Errorf("unknown token flag '%s'", name)
+	}
+	return flags, nil
+}
+
+// Names of the syntax kinds in the JSON schema, never change a name, only add new ones
+var syntaxKindNames = [...]string{
+	SK_Unknown:                           "Unknown",
+	SK_EndOfFile:                         "EndOfFile",
+	SK_SingleLineCommentTrivia:           "SingleLineCommentTrivia",
+	SK_MultiLineCommentTrivia:            "MultiLineCommentTrivia",
+	SK_NumberLiteral:                     "NumberLiteral",
+	SK_StringLiteral:                     "StringLiteral",
+	SK_DateLiteral:                       "DateLiteral",
+	SK_DurationLiteral:                   "DurationLiteral",
+	SK_NoSubstitutionTemplateLiteral:     "NoSubstitutionTemplateLiteral",
+	SK_TemplateHead:                      "TemplateHead",
+	SK_TemplateMiddle:                    "TemplateMiddle",
+	SK_TemplateTail:                      "TemplateTail",
+	SK_OpenParen:                         "OpenParen",
+	SK_CloseParen:                        "CloseParen",
+	SK_OpenBracket:                       "OpenBracket",
+	SK_CloseBracket:                      "CloseBracket",
+	SK_OpenBrace:                         "OpenBrace",
+	SK_CloseBrace:                        "CloseBrace",
+	SK_Dot:                               "Dot",
+	SK_DotDotDot:                         "DotDotDot",
+	SK_Comma:                             "Comma",
+	SK_Semicolon:                         "Semicolon",
+	SK_LessThan:                          "LessThan",
+	SK_GreaterThan:                       "GreaterThan",
+	SK_LessThanEquals:                    "LessThanEquals",
+	SK_GreaterThanEquals:                 "GreaterThanEquals",
+	SK_EqualsEquals:                      "EqualsEquals",
+	SK_EqualsEqualsEquals:                "EqualsEqualsEquals",
+	SK_ExclamationEquals:                 "ExclamationEquals",
+	SK_ExclamationEqualsEquals:           "ExclamationEqualsEquals",
+	SK_Plus:                              "Plus",
+	SK_Minus:                             "Minus",
+	SK_Asterisk:                          "Asterisk",
+	SK_Slash:                             "Slash",
+	SK_Percent:                           "Percent",
+	SK_AsteriskAsterisk:                  "AsteriskAsterisk",
+	SK_LessThanLessThan:                  "LessThanLessThan",
+	SK_GreaterThanGreaterThan:            "GreaterThanGreaterThan",
+	SK_GreaterThanGreaterThanGreaterThan: "GreaterThanGreaterThanGreaterThan",
+	SK_Ampersand:                         "Ampersand",
+	SK_Bar:                               "Bar",
+	SK_Caret:                             "Caret",
+	SK_AmpersandAmpersand:                "AmpersandAmpersand",
+	SK_BarBar:                            "BarBar",
+	SK_QuestionQuestion:                  "QuestionQuestion",
+	SK_BarGreaterThan:                    "BarGreaterThan",
+	SK_Exclamation:                       "Exclamation",
+	SK_ExclamationDot:                    "ExclamationDot",
+	SK_QuestionDot:                       "QuestionDot",
+	SK_ExclamationExclamation:            "ExclamationExclamation",
+	SK_Tilde:                             "Tilde",
+	SK_Question:                          "Question",
+	SK_Colon:                             "Colon",
+	SK_EqualsGreaterThan:                 "EqualsGreaterThan",
+	SK_Equals:                            "Equals",
+	SK_PlusEquals:                        "PlusEquals",
+	SK_MinusEquals:                       "MinusEquals",
+	SK_AsteriskEquals:                    "AsteriskEquals",
+	SK_AsteriskAsteriskEquals:            "AsteriskAsteriskEquals",
+	SK_SlashEquals:                       "SlashEquals",
+	SK_PercentEquals:                     "PercentEquals",
+	SK_LessThanLessThanEquals:            "LessThanLessThanEquals",
+	SK_GreaterThanGreaterThanEquals:      "GreaterThanGreaterThanEquals",
+	SK_GreaterThanGreaterThanGreaterThanEquals: "GreaterThanGreaterThanGreaterThanEquals",
+	SK_AmpersandEquals:                         "AmpersandEquals",
+	SK_BarEquals:                               "BarEquals",
+	SK_CaretEquals:                             "CaretEquals",
+	SK_Identifier:                              "Identifier",
+	SK_TrueKeyword:                             "TrueKeyword",
+	SK_FalseKeyword:                            "FalseKeyword",
+	SK_NullKeyword:                             "NullKeyword",
+	SK_ThisKeyword:                             "ThisKeyword",
+	SK_CtxKeyword:                              "CtxKeyword",
+	SK_TypeofKeyword:                           "TypeofKeyword",
+	SK_InKeyword:                               "InKeyword",
+	SK_NotKeyword:                              "NotKeyword",
+	SK_LetKeyword:                              "LetKeyword",
+}
+
+var tokenFlagList = []struct {
+	flag TokenFlags
+	name string
+}{
+	{TF_PrecedingLineBreak, "PrecedingLineBreak"},
+	{TF_Scientific, "Scientific"},
+	{TF_Decimal, "Decimal"},
+	{TF_Octal, "Octal"},
+	{TF_HexSpecifier, "HexSpecifier"},
+	{TF_BinarySpecifier, "BinarySpecifier"},
+	{TF_OctalSpecifier, "OctalSpecifier"},
+	{TF_ContainsSeparator, "ContainsSeparator"},
+	{TF_UnicodeEscape, "UnicodeEscape"},
+	{TF_Percent, "Percent"},
+	{TF_DigitGroup, "DigitGroup"},
+	{TF_QuotedIdentifier, "QuotedIdentifier"},
+}
@@ -0,0 +1,148 @@
+package formula
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalAST(t *testing.T) {
+	codes := []string{
+		"a + b * c",
+		"let $t = (1, 2); $t += 3, $t",
+		"!a && typeof b == 'number' ? x?.y!.z : c[0]",
+		"{a: 1, 'b c': 2, d, ...e}",
+		"`x ${a} y ${[1, ...b]} z`",
+		"[1, 2, 3] |> map(x => x * 2) |> join(',')",
+		"match strict (a) { 1, 2 => 'x', _ => 'y' }",
+		"15% + 0x1f + 1_000 + #2024-05-01# + 2h30m",
+		"@'Order Total' not in [1, 2]",
+		"",
+	}
+	for _, code := range codes {
+		source := ParseSourceCodeWithDiagnostics([]byte(code), WithPercentLiterals())
+		data, err := MarshalAST(source)
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		node, err := UnmarshalAST(data)
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		loaded := node.(*SourceCode)
+		if astToString(loaded) != astToString(source) {
+			t.Errorf("%s: expect %q, got %q", code, astToString(source), astToString(loaded))
+		}
+		if string(loaded.Text) != code {
+			t.Errorf("%s: got text %q", code, string(loaded.Text))
+		}
+		again, err := MarshalAST(loaded)
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("%s: marshal after unmarshal differs\n%s\n%s", code, data, again)
+		}
+	}
+}
+
+func TestUnmarshalASTLinks(t *testing.T) {
+	source, err := ParseSourceCode([]byte("max(a + 1, 1)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MarshalAST(source.Expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := UnmarshalAST(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := node.(*CallExpression)
+	binary := call.Arguments.At(0).(*BinaryExpression)
+	if call.Parent() != nil || binary.Parent() != call || binary.Operator.Parent() != binary {
+		t.Errorf("parent links are not set")
+	}
+	if call.ID() != 1 || binary.ID() <= call.ID() || binary.Right.ID() <= binary.Left.ID() {
+		t.Errorf("expect ids in source order, got %d %d %d %d", call.ID(), binary.ID(), binary.Left.ID(), binary.Right.ID())
+	}
+	if binary.Pos() != 4 || binary.End() != 9 {
+		t.Errorf("expect range 4-9, got %d-%d", binary.Pos(), binary.End())
+	}
+
+	runner := NewRunner()
+	runner.SetThis(map[string]interface{}{"a": 2})
+	v, err := runner.Resolve(context.Background(), call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != float64(3) {
+		t.Errorf("expect 3, got %v", v)
+	}
+}
+
+func TestMarshalASTSchema(t *testing.T) {
+	source, err := ParseSourceCode([]byte("a >= 15%"), WithPercentLiterals())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MarshalAST(source.Expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Version int
+		Root    struct {
+			Kind     string
+			Operator struct{ Token string }
+			Right    struct {
+				Token string
+				Value string
+				Flags []string
+			}
+		}
+	}
+	if err = json.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.Version != ASTSchemaVersion || document.Root.Kind != "BinaryExpression" ||
+		document.Root.Operator.Token != "GreaterThanEquals" || document.Root.Right.Token != "NumberLiteral" ||
+		document.Root.Right.Value != "15" || len(document.Root.Right.Flags) != 1 || document.Root.Right.Flags[0] != "Percent" {
+		t.Errorf("unexpected json %s", data)
+	}
+
+	errors := map[string]string{
+		`{"version":2,"root":{"kind":"Identifier"}}`: "unsupported ast schema version 2",
+		`{"version":1}`:                                             "ast has no root node",
+		`{"version":1,"root":{"kind":"Lambda"}}`:                    "unknown node kind 'Lambda'",
+		`{"version":1,"root":{"kind":"Token","token":"Spaceship"}}`: "unknown syntax kind 'Spaceship'",
+		`{"version":1,"root":{"kind":"TypeOfExpression","expression":{"kind":"Token","token":"Plus"}}}`:     "node kind 'Token' is not an expression",
+		`{"version":1,"root":{"kind":"LiteralExpression","token":"Spaceship"}}`:                             "unknown syntax kind 'Spaceship'",
+		`{"version":1,"root":{"kind":"BinaryExpression","left":{"kind":"Identifier","value":"a"}}}`:         "BinaryExpression has no operator",
+		`{"version":1,"root":{"kind":"PrefixUnaryExpression","operator":{"kind":"Token","token":"Minus"}}}`: "PrefixUnaryExpression has no operand",
+		`{"version":1,"root":{"kind":"ConditionalExpression","condition":{"kind":"Identifier"}}}`:           "ConditionalExpression has no whenTrue",
+		`{"version":1,"root":{"kind":"SelectorExpression","expression":{"kind":"Identifier"}}}`:             "SelectorExpression has no name",
+		`{"version":1,"root":{"kind":"CallExpression","arguments":{"nodes":[]}}}`:                           "CallExpression has no expression",
+		`{"version":1,"root":{"kind":"MatchArm","patterns":{"nodes":[]}}}`:                                  "MatchArm has no result",
+		`{"version":1,"root":{"kind":"VariableStatement","name":{"kind":"Identifier","value":"a"}}}`:        "VariableStatement has no initializer",
+	}
+	for text, expect := range errors {
+		_, err := UnmarshalAST([]byte(text))
+		if err == nil || err.Error() != expect {
+			t.Errorf("%s: expect error %q, got %v", text, expect, err)
+		}
+	}
+}
+
+type unknownNode struct {
+	Identifier
+}
+
+func TestMarshalASTError(t *testing.T) {
+	// The error of a child isn't lost when a list is encoded after it
+	call := &CallExpression{Expression: new(unknownNode), Arguments: new(NodeList[Expression])}
+	if _, err := MarshalAST(call); err == nil || err.Error() != "unknown node type *formula.unknownNode" {
+		t.Errorf("expect unknown node type error, got %v", err)
+	}
+}
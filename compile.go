@@ -0,0 +1,794 @@
+package formula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ericlagergren/decimal"
+)
+
+// Program is a compiled formula. Compile resolves the functions and literals of the formula once,
+// Run evaluates it without walking the AST again, so a Program is made for running the same
+// formula over many rows. A Program is safe for concurrent use.
+type Program struct {
+	source *SourceCode
+	eval   evalFunc
+}
+
+// evalFunc evaluates a compiled expression, the runner holds the fields and local variables of one run.
+type evalFunc func(ctx context.Context, r *Runner) (interface{}, error)
+
+// chainFunc evaluates a compiled selector, element access or call expression, see resolveChainExpression.
+type chainFunc func(ctx context.Context, r *Runner) (interface{}, bool, error)
+
+type CompileOption func(c *compiler)
+
+// WithFunction adds a function to the program, it shadows the builtin function and the field
+// with the same name. fun is called like a builtin function, e.g. `func(s string) (string, error)`.
+func WithFunction(name string, fun interface{}) CompileOption {
+	return func(c *compiler) {
+		c.funcs[name] = fun
+	}
+}
+
+// Compile compiles the expression of source, it fails with a *ParseError when source has an
+// error diagnostic. The program evaluates like Runner.Resolve.
+func Compile(source *SourceCode, opts ...CompileOption) (*Program, error) {
+	for _, diagnostic := range source.Diagnostics {
+		if diagnostic.Category == Error {
+			return nil, &ParseError{Source: source, Diagnostics: source.Diagnostics}
+		}
+	}
+	var c = &compiler{funcs: map[string]interface{}{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for name, fun := range c.funcs {
+		if newFuncSignature(fun) == nil {
+			return nil, fmt.Errorf("function '%s' is %T, not a function", name, fun)
+		}
+	}
+	eval, err := c.compile(source.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{source: source, eval: eval}, nil
+}
+
+// Source returns the source code the program is compiled from.
+func (p *Program) Source() *SourceCode {
+	return p.source
+}
+
+// Run evaluates the program with the fields of env, assignments like `$a = 1` are stored in env.
+func (p *Program) Run(ctx context.Context, env map[string]interface{}) (interface{}, error) {
+	var r = NewRunner()
+	r.SetThis(env)
+	res, err := p.eval(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return try2Float64(res), nil
+}
+
+type compiler struct {
+	funcs map[string]interface{}
+	// local names of the enclosing blocks and arrow functions
+	locals []map[string]bool
+}
+
+func (c *compiler) isLocal(name string) bool {
+	for _, names := range c.locals {
+		if names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupFunction returns the function or builtin value a name refers to when it is known at compile time.
+func (c *compiler) lookupFunction(name string) (interface{}, bool) {
+	if c.isLocal(name) {
+		return nil, false
+	}
+	if fun, ok := c.funcs[name]; ok {
+		return fun, true
+	}
+	return innerMap.Load(name)
+}
+
+// runtimeError is an error the interpreter reports when the expression is evaluated, the program
+// reports it at the same time.
+func runtimeError(err error) evalFunc {
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		return nil, err
+	}
+}
+
+func (c *compiler) compile(expr Expression) (evalFunc, error) {
+	switch n := expr.(type) {
+	case *Identifier:
+		return c.compileIdentifier(n), nil
+	case *PrefixUnaryExpression:
+		return c.compilePrefixUnaryExpression(n)
+	case *BinaryExpression:
+		return c.compileBinaryExpression(n)
+	case *ArrayLiteralExpression:
+		elements, err := c.compileElements(n.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			return elements(ctx, r)
+		}, nil
+	case *ObjectLiteralExpression:
+		return c.compileObjectLiteralExpression(n)
+	case *TemplateExpression:
+		return c.compileTemplateExpression(n)
+	case *ParenthesizedExpression:
+		return c.compile(n.Expression)
+	case *LiteralExpression:
+		return c.compileLiteralExpression(n)
+	case *SelectorExpression, *ElementAccessExpression, *CallExpression:
+		chain, err := c.compileChain(n)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			v, _, err := chain(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			return formatInput(v)
+		}, nil
+	case *ConditionalExpression:
+		return c.compileConditionalExpression(n)
+	case *MatchExpression:
+		return c.compileMatchExpression(n)
+	case *TypeOfExpression:
+		value, err := c.compile(n.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			v, err := value(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			return typeOfValue(v), nil
+		}, nil
+	case *ArrowFunction:
+		return c.compileArrowFunction(n)
+	case *BlockExpression:
+		return c.compileBlockExpression(n)
+	default:
+		return runtimeError(errors.New("unknown expression type")), nil
+	}
+}
+
+func (c *compiler) compileIdentifier(expr *Identifier) evalFunc {
+	var name = expr.Value
+	if c.isLocal(name) {
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			// Arguments of lambdas called by functions are not formatted yet
+			if v, ok := r.scope.lookup(name); ok {
+				return formatInput(v)
+			}
+			if fun, ok := c.funcs[name]; ok {
+				return fun, nil
+			}
+			if v, ok := innerMap.Load(name); ok {
+				return v, nil
+			}
+			return formatInput(r.this[name])
+		}
+	}
+	if v, ok := c.lookupFunction(name); ok {
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			return v, nil
+		}
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		return formatInput(r.this[name])
+	}
+}
+
+func (c *compiler) compileLiteralExpression(expr *LiteralExpression) (evalFunc, error) {
+	switch expr.Token {
+	case SK_ThisKeyword:
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			return r.this, nil
+		}, nil
+	case SK_CtxKeyword:
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			return ctx, nil
+		}, nil
+	}
+	v, err := NewRunner().resolveLiteralExpression(context.Background(), expr)
+	if err != nil {
+		return nil, err
+	}
+	// Functions may change a number argument, every run gets its own copy
+	if number, ok := v.(*decimal.Big); ok {
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			return newDecimalBig().Copy(number), nil
+		}, nil
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		return v, nil
+	}, nil
+}
+
+func (c *compiler) compilePrefixUnaryExpression(expr *PrefixUnaryExpression) (evalFunc, error) {
+	operand, err := c.compile(expr.Operand)
+	if err != nil {
+		return nil, err
+	}
+	var operation func(r *Runner, v interface{}) (interface{}, error)
+	switch expr.Operator.Token {
+	case SK_Plus:
+		operation = (*Runner).resolvePlusUnaryExpression
+	case SK_Minus:
+		operation = (*Runner).resolveMinusUnaryExpression
+	case SK_Exclamation:
+		operation = (*Runner).resolveExclamationUnaryExpression
+	case SK_Tilde:
+		operation = (*Runner).resolveTildeUnaryExpression
+	case SK_ExclamationExclamation:
+		operation = (*Runner).resolveExclamationExclamationUnaryExpression
+	default:
+		return runtimeError(errors.New("unknown unary expression")), nil
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		v, err := operand(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		res, err := operation(r, v)
+		if err != nil {
+			return nil, err
+		}
+		return formatInput(res)
+	}, nil
+}
+
+func (c *compiler) compileBinaryExpression(expr *BinaryExpression) (evalFunc, error) {
+	var operator = expr.Operator.Token
+	if operator.IsAssignmentOperator() {
+		return c.compileAssignment(expr)
+	}
+	if operator == SK_BarGreaterThan {
+		return c.compilePipeline(expr)
+	}
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	// Logical expression only evaluates the right side when the left side can't decide the result
+	switch operator {
+	case SK_AmpersandAmpersand, SK_BarBar, SK_QuestionQuestion:
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			v1, err := left(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			var decided bool
+			switch operator {
+			case SK_AmpersandAmpersand:
+				decided = !r.toBool(v1)
+			case SK_BarBar:
+				decided = r.toBool(v1)
+			default:
+				decided = !IsNull(v1)
+			}
+			if decided {
+				return v1, nil
+			}
+			return right(ctx, r)
+		}, nil
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		v1, err := left(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		v2, err := right(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		res, err := r.resolveBinaryOperation(expr, operator, v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		return formatInput(res)
+	}, nil
+}
+
+func (c *compiler) compileAssignment(expr *BinaryExpression) (evalFunc, error) {
+	identifier, ok := expr.Left.(*Identifier)
+	if !ok {
+		return runtimeError(errors.New("assignment expression left expression is not identifier")), nil
+	}
+	var name = identifier.Value
+	if !strings.HasPrefix(name, "$") {
+		return runtimeError(fmt.Errorf("assignment expression left identifier must start of '$' but %s", name)), nil
+	}
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Operator.Token == SK_Equals {
+		return func(ctx context.Context, r *Runner) (interface{}, error) {
+			v, err := right(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			r.SetThisValue(name, v)
+			return v, nil
+		}, nil
+	}
+	var operator = compoundAssignmentOperators[expr.Operator.Token]
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		v1, err := left(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		v2, err := right(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		res, err := r.resolveBinaryOperation(expr, operator, v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		r.SetThisValue(name, res)
+		return formatInput(res)
+	}, nil
+}
+
+// compilePipeline compiles `x |> f(a)` as `f(x, a)` and `x |> f` as `f(x)`
+func (c *compiler) compilePipeline(expr *BinaryExpression) (evalFunc, error) {
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.Right.(*CallExpression)
+	if !ok {
+		call = &CallExpression{Expression: expr.Right}
+	}
+	chain, err := c.compileCall(call, left)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		v, _, err := chain(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return formatInput(v)
+	}, nil
+}
+
+// compileChain compiles a selector, element access or call expression, see resolveChainExpression.
+func (c *compiler) compileChain(expr Expression) (chainFunc, error) {
+	switch n := expr.(type) {
+	case *SelectorExpression:
+		inner, err := c.compileChain(n.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, r *Runner) (interface{}, bool, error) {
+			v, skipped, err := inner(ctx, r)
+			if err != nil || skipped {
+				return nil, skipped, err
+			}
+			if n.Optional && IsNull(v) {
+				return nil, true, nil
+			}
+			res, err := r.resolveSelectorExpression(ctx, n, v)
+			return res, false, err
+		}, nil
+	case *ElementAccessExpression:
+		inner, err := c.compileChain(n.Expression)
+		if err != nil {
+			return nil, err
+		}
+		argument, err := c.compile(n.ArgumentExpression)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, r *Runner) (interface{}, bool, error) {
+			v, skipped, err := inner(ctx, r)
+			if err != nil || skipped {
+				return nil, skipped, err
+			}
+			if n.Optional && IsNull(v) {
+				return nil, true, nil
+			}
+			index, err := argument(ctx, r)
+			if err != nil {
+				return nil, false, err
+			}
+			value, err := getObjectValueFromIndex(v, index)
+			if err != nil {
+				return nil, false, err
+			}
+			return formatNilValue(value), false, nil
+		}, nil
+	case *CallExpression:
+		return c.compileCall(n, nil)
+	default:
+		eval, err := c.compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, r *Runner) (interface{}, bool, error) {
+			v, err := eval(ctx, r)
+			return v, false, err
+		}, nil
+	}
+}
+
+// compileCall compiles a call, a function known at compile time is analyzed once. The value of
+// leading is passed before the arguments.
+func (c *compiler) compileCall(expr *CallExpression, leading evalFunc) (chainFunc, error) {
+	// 函数名仅用于错误信息, 例如 `(x => x)(1)` 没有名字
+	var name = "anonymous"
+	if names, err := resolveCallNames(expr.Expression); err == nil {
+		name = strings.Join(names, ".")
+	}
+	arguments, err := c.compileElements(expr.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	// The leading value of a pipeline is evaluated before the function and the arguments
+	var evalLeading = func(ctx context.Context, r *Runner) ([]interface{}, error) {
+		if leading == nil {
+			return nil, nil
+		}
+		v, err := leading(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+	var withLeading = func(args []interface{}, rest []interface{}) []interface{} {
+		if len(args) == 0 {
+			return rest
+		}
+		return append(args, rest...)
+	}
+
+	if identifier, ok := expr.Expression.(*Identifier); ok {
+		if fun, ok := c.lookupFunction(identifier.Value); ok {
+			if signature := newFuncSignature(fun); signature != nil {
+				return func(ctx context.Context, r *Runner) (interface{}, bool, error) {
+					args, err := evalLeading(ctx, r)
+					if err != nil {
+						return nil, false, err
+					}
+					rest, err := arguments(ctx, r)
+					if err != nil {
+						return nil, false, err
+					}
+					res, err := signature.call(ctx, name, withLeading(args, rest))
+					return res, false, err
+				}, nil
+			}
+		}
+	}
+
+	callee, err := c.compileChain(expr.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, bool, error) {
+		args, err := evalLeading(ctx, r)
+		if err != nil {
+			return nil, false, err
+		}
+		fun, skipped, err := callee(ctx, r)
+		if err != nil || skipped {
+			return nil, skipped, err
+		}
+		rest, err := arguments(ctx, r)
+		if err != nil {
+			return nil, false, err
+		}
+		args = withLeading(args, rest)
+		if lambda, ok := fun.(*Lambda); ok {
+			res, err := lambda.Call(ctx, args...)
+			return res, false, err
+		}
+		signature := newFuncSignature(fun)
+		if signature == nil {
+			return nil, false, fmt.Errorf("expr %s value not is function", name)
+		}
+		res, err := signature.call(ctx, name, args)
+		return res, false, err
+	}, nil
+}
+
+// compileElements compiles array literal elements or call arguments, spread elements are expanded in place.
+func (c *compiler) compileElements(elements *NodeList[Expression]) (func(ctx context.Context, r *Runner) ([]interface{}, error), error) {
+	type element struct {
+		eval   evalFunc
+		spread bool
+	}
+	var list []element
+	for _, item := range elements.Array() {
+		if spread, ok := item.(*SpreadElement); ok {
+			eval, err := c.compile(spread.Expression)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, element{eval: eval, spread: true})
+			continue
+		}
+		eval, err := c.compile(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, element{eval: eval})
+	}
+	return func(ctx context.Context, r *Runner) ([]interface{}, error) {
+		var result = make([]interface{}, 0, len(list))
+		for _, e := range list {
+			v, err := e.eval(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			if !e.spread {
+				result = append(result, v)
+				continue
+			}
+			expands, err := expandArrayArgument(v)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range expands {
+				fv, err := formatInput(item)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, fv)
+			}
+		}
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result, nil
+	}, nil
+}
+
+func (c *compiler) compileObjectLiteralExpression(expr *ObjectLiteralExpression) (evalFunc, error) {
+	type property struct {
+		name   string
+		eval   evalFunc
+		spread bool
+	}
+	var properties []property
+	for _, p := range expr.Properties.Array() {
+		switch n := p.(type) {
+		case *PropertyAssignment:
+			eval, err := c.compile(n.Initializer)
+			if err != nil {
+				return nil, err
+			}
+			properties = append(properties, property{name: propertyName(n.Name), eval: eval})
+		case *ShorthandPropertyAssignment:
+			properties = append(properties, property{name: n.Name.Value, eval: c.compileIdentifier(n.Name)})
+		case *SpreadAssignment:
+			eval, err := c.compile(n.Expression)
+			if err != nil {
+				return nil, err
+			}
+			properties = append(properties, property{eval: eval, spread: true})
+		}
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		result := map[string]interface{}{}
+		for _, p := range properties {
+			v, err := p.eval(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			if !p.spread {
+				result[p.name] = v
+				continue
+			}
+			if err = spreadObject(result, v); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}, nil
+}
+
+func (c *compiler) compileTemplateExpression(expr *TemplateExpression) (evalFunc, error) {
+	var spans []evalFunc
+	for _, span := range expr.Spans.Array() {
+		eval, err := c.compile(span.Expression)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, eval)
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		var result strings.Builder
+		result.WriteString(expr.Head)
+		for i, span := range expr.Spans.Array() {
+			v, err := spans[i](ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			// null 输出为空字符串
+			if !IsNull(v) {
+				result.WriteString(convToString(v))
+			}
+			result.WriteString(span.Literal)
+		}
+		return result.String(), nil
+	}, nil
+}
+
+func (c *compiler) compileConditionalExpression(expr *ConditionalExpression) (evalFunc, error) {
+	condition, err := c.compile(expr.Condition)
+	if err != nil {
+		return nil, err
+	}
+	whenTrue, err := c.compile(expr.WhenTrue)
+	if err != nil {
+		return nil, err
+	}
+	whenFalse, err := c.compile(expr.WhenFalse)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		cond, err := condition(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if r.toBool(cond) {
+			return whenTrue(ctx, r)
+		}
+		return whenFalse(ctx, r)
+	}, nil
+}
+
+func (c *compiler) compileMatchExpression(expr *MatchExpression) (evalFunc, error) {
+	type arm struct {
+		patterns []evalFunc
+		result   evalFunc
+	}
+	value, err := c.compile(expr.Expression)
+	if err != nil {
+		return nil, err
+	}
+	var arms []arm
+	for _, a := range expr.Arms.Array() {
+		var compiled arm
+		for _, pattern := range a.Patterns.Array() {
+			eval, err := c.compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled.patterns = append(compiled.patterns, eval)
+		}
+		if compiled.result, err = c.compile(a.Result); err != nil {
+			return nil, err
+		}
+		arms = append(arms, compiled)
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		v, err := value(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range arms {
+			matched := len(a.patterns) == 0
+			for _, pattern := range a.patterns {
+				pv, err := pattern(ctx, r)
+				if err != nil {
+					return nil, err
+				}
+				if expr.Strict {
+					matched = r.valueEqualTo(v, pv)
+				} else {
+					matched = r.valueLikeEqualTo(v, pv)
+				}
+				if matched {
+					break
+				}
+			}
+			if matched {
+				return a.result(ctx, r)
+			}
+		}
+		return nil, nil
+	}, nil
+}
+
+func (c *compiler) compileArrowFunction(expr *ArrowFunction) (evalFunc, error) {
+	var params = map[string]bool{}
+	for _, param := range expr.Parameters.Array() {
+		params[param.Value] = true
+	}
+	c.locals = append(c.locals, params)
+	body, err := c.compile(expr.Body)
+	c.locals = c.locals[:len(c.locals)-1]
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		return &Lambda{runner: r, scope: r.scope, expr: expr, body: body}, nil
+	}, nil
+}
+
+// compileBlockExpression compiles the statements of a block, they run in a new scope like resolveBlockExpression.
+func (c *compiler) compileBlockExpression(expr *BlockExpression) (evalFunc, error) {
+	type statement struct {
+		name string // name of a `let` variable
+		eval evalFunc
+	}
+	// Arrow functions may reference variables declared after them
+	var names = map[string]bool{}
+	for _, s := range expr.Statements.Array() {
+		if variable, ok := s.(*VariableStatement); ok {
+			names[variable.Name.Value] = true
+		}
+	}
+	c.locals = append(c.locals, names)
+	defer func() { c.locals = c.locals[:len(c.locals)-1] }()
+
+	var statements []statement
+	for _, s := range expr.Statements.Array() {
+		switch n := s.(type) {
+		case *VariableStatement:
+			eval, err := c.compile(n.Initializer)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement{name: n.Name.Value, eval: eval})
+		case *ExpressionStatement:
+			eval, err := c.compile(n.Expression)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement{eval: eval})
+		default:
+			return runtimeError(errors.New("unknown statement type")), nil
+		}
+	}
+	return func(ctx context.Context, r *Runner) (interface{}, error) {
+		saved := r.scope
+		r.scope = newScope(saved)
+		defer func() { r.scope = saved }()
+
+		var res interface{}
+		for _, s := range statements {
+			v, err := s.eval(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			if len(s.name) > 0 {
+				r.scope.values[s.name] = v
+				res = nil
+			} else {
+				res = v
+			}
+		}
+		return res, nil
+	}, nil
+}
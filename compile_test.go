@@ -0,0 +1,202 @@
+package formula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func compileEnv() map[string]interface{} {
+	return map[string]interface{}{
+		"price":    12.5,
+		"qty":      3,
+		"discount": 0.1,
+		"name":     "Milk",
+		"tags":     []string{"a", "b"},
+		"nums":     []interface{}{3, 1, 2},
+		"order":    map[string]interface{}{"id": 7, "lines": []interface{}{map[string]interface{}{"qty": 2}}},
+		"empty":    nil,
+	}
+}
+
+func TestCompileValue(t *testing.T) {
+	codes := []string{
+		"price * qty * (1 - discount)",
+		"-price + +qty - ~qty",
+		"!empty && !!name",
+		"price > 10 ? 'high' : 'low'",
+		"empty ?? 'none'",
+		"max(price, qty, 20)",
+		"abs(-price) + round(1.5) + sqrt(16)",
+		"upper(name) + lower(name)",
+		"startWith(name, 'M') || contains(name, 'x')",
+		"len(name) + left(name, 2)",
+		"order.id + order.lines[0].qty + order?.missing?.x",
+		"nums[-1] + nums[0]",
+		"[1, ...nums, 4]",
+		"{a: 1, 'b c': price, name, ...order}",
+		"`${name} x ${qty}`",
+		"nums |> map(x => x * 2) |> join(',')",
+		"map(nums, x => x + qty)",
+		"reduce(nums, (a, b) => a + b, 0)",
+		"sortBy(nums, x => -x)",
+		"match (qty) { 1, 2 => 'few', 3 => 'three', _ => 'many' }",
+		"match strict (name) { 'milk' => 1, _ => 2 }",
+		"let $t = price; $t += 1; $t * 2",
+		"let a = 2; let f = x => x * a; f(3) + a",
+		"$total = price * qty, $total",
+		"typeof price + typeof name + typeof 2h",
+		"#2024-01-01# + 1d > #2024-01-01#",
+		"15% * 200 + 0x10",
+		"true == 1 && 'a' in tags && 3 not in [1, 2]",
+		"this.name + ctx.Value('x')",
+		"@'na' + 'me'",
+		"finite(1 / 0) + finite(null)",
+	}
+	ctx := context.WithValue(context.Background(), "x", "y")
+	for _, code := range codes {
+		source, err := ParseSourceCode([]byte(code), WithPercentLiterals())
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		runner := NewRunner()
+		runner.SetThis(compileEnv())
+		expect, expectErr := runner.Resolve(ctx, source.Expression)
+
+		program, err := Compile(source)
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		v, err := program.Run(ctx, compileEnv())
+		if fmt.Sprint(err) != fmt.Sprint(expectErr) {
+			t.Errorf("%s: expect error %v, got %v", code, expectErr, err)
+			continue
+		}
+		if fmt.Sprint(v) != fmt.Sprint(expect) {
+			t.Errorf("%s: expect %v, got %v", code, expect, v)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	codes := []string{
+		"upper(1, 2)",
+		"name()",
+		"missing.x()",
+		"order!.missing!.x",
+		"nums[5]",
+		"[...price]",
+		"'a' + 1 > #2024-01-01#",
+	}
+	for _, code := range codes {
+		source, err := ParseSourceCode([]byte(code))
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		runner := NewRunner()
+		runner.SetThis(compileEnv())
+		_, expectErr := runner.Resolve(context.Background(), source.Expression)
+
+		program, err := Compile(source)
+		if err != nil {
+			t.Fatalf("%s %v", code, err)
+		}
+		_, err = program.Run(context.Background(), compileEnv())
+		if fmt.Sprint(err) != fmt.Sprint(expectErr) {
+			t.Errorf("%s: expect error %v, got %v", code, expectErr, err)
+		}
+	}
+
+	source := ParseSourceCodeWithDiagnostics([]byte("a +"))
+	var parseError *ParseError
+	if _, err := Compile(source); !errors.As(err, &parseError) {
+		t.Errorf("expect parse error, got %v", err)
+	}
+	source, _ = ParseSourceCode([]byte("f(1)"))
+	if _, err := Compile(source, WithFunction("f", 1)); err == nil || err.Error() != "function 'f' is int, not a function" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCompileWithFunction(t *testing.T) {
+	source, err := ParseSourceCode([]byte("greet(name) + ':' + upper(name) + ':' + (name |> greet)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(source,
+		WithFunction("greet", func(s string) (string, error) { return "hi " + s, nil }),
+		WithFunction("upper", func(ctx context.Context, s string) (string, error) { return strings.Repeat(s, 2), nil }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The program is reused for every row
+	for _, name := range []string{"a", "b"} {
+		v, err := program.Run(context.Background(), map[string]interface{}{"name": name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := "hi " + name + ":" + name + name + ":hi " + name
+		if v != expect {
+			t.Errorf("expect %s, got %v", expect, v)
+		}
+	}
+
+	// Assignments are stored in env
+	source, err = ParseSourceCode([]byte("$n += 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err = Compile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]interface{}{"$n": 1}
+	if _, err = program.Run(context.Background(), env); err != nil {
+		t.Fatal(err)
+	}
+	if n := try2Float64(env["$n"]); !reflect.DeepEqual(n, float64(2)) {
+		t.Errorf("expect $n 2, got %v", n)
+	}
+}
+
+const benchmarkFormula = "price * qty * (1 - discount) + max(price, 5) > 20 && startWith(name, 'M') ? round(price * qty) : abs(price - 100)"
+
+func BenchmarkRunnerResolve(b *testing.B) {
+	source, err := ParseSourceCode([]byte(benchmarkFormula))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	env := compileEnv()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runner := NewRunner()
+		runner.SetThis(env)
+		if _, err := runner.Resolve(ctx, source.Expression); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	source, err := ParseSourceCode([]byte(benchmarkFormula))
+	if err != nil {
+		b.Fatal(err)
+	}
+	program, err := Compile(source)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	env := compileEnv()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Run(ctx, env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -64,7 +64,7 @@ var (
 	M_Multiple_consecutive_numeric_separators_are_not_permitted = &DiagnosticMessage{
 		Code:     1301,
 		Category: Error,
-		Message:  "multiple cconsecutive numeric separators are not permitted",
+		Message:  "multiple consecutive numeric separators are not permitted",
 	}
 
 	M_Numeric_separators_are_not_allowed_here = &DiagnosticMessage{
@@ -84,4 +84,136 @@ var (
 		Category: Error,
 		Message:  "trailing comma not allowed",
 	}
+
+	M_An_element_access_expression_should_take_an_argument = &DiagnosticMessage{
+		Code:     1011,
+		Category: Error,
+		Message:  "an element access expression should take an argument",
+	}
+
+	M_Parameter_declaration_expected = &DiagnosticMessage{
+		Code:     1138,
+		Category: Error,
+		Message:  "parameter declaration expected",
+	}
+
+	M_Duplicate_identifier_0 = &DiagnosticMessage{
+		Code:     2300,
+		Category: Error,
+		Message:  "duplicate identifier '{0}'",
+	}
+
+	M_Property_assignment_expected = &DiagnosticMessage{
+		Code:     1136,
+		Category: Error,
+		Message:  "property assignment expected",
+	}
+
+	M_Unterminated_template_literal = &DiagnosticMessage{
+		Code:     1160,
+		Category: Error,
+		Message:  "unterminated template literal",
+	}
+
+	M_Asterisk_Slash_expected = &DiagnosticMessage{
+		Code:     1010,
+		Category: Error,
+		Message:  "'*/' expected",
+	}
+
+	M_An_unary_expression_with_the_0_operator_is_not_allowed_in_the_left_hand_side_of_an_exponentiation_expression = &DiagnosticMessage{
+		Code:     17006,
+		Category: Error,
+		Message:  "an unary expression with the '{0}' operator is not allowed in the left-hand side of an exponentiation expression, consider enclosing the expression in parentheses",
+	}
+
+	M_The_left_hand_side_of_an_assignment_expression_must_be_a_local_variable = &DiagnosticMessage{
+		Code:     2364,
+		Category: Error,
+		Message:  "the left-hand side of an assignment expression must be a local variable starting with '$'",
+	}
+
+	M_Declaration_or_statement_expected = &DiagnosticMessage{
+		Code:     1128,
+		Category: Error,
+		Message:  "declaration or statement expected",
+	}
+
+	M_Cannot_redeclare_block_scoped_variable_0 = &DiagnosticMessage{
+		Code:     2451,
+		Category: Error,
+		Message:  "cannot redeclare block-scoped variable '{0}'",
+	}
+
+	M_Block_scoped_variable_0_used_before_its_declaration = &DiagnosticMessage{
+		Code:     2448,
+		Category: Error,
+		Message:  "block-scoped variable '{0}' used before its declaration",
+	}
+
+	M_Match_arm_expected = &DiagnosticMessage{
+		Code:     1450,
+		Category: Error,
+		Message:  "match arm expected",
+	}
+
+	M_A_match_expression_can_only_have_one_default_arm = &DiagnosticMessage{
+		Code:     1451,
+		Category: Error,
+		Message:  "a match expression can only have one default arm",
+	}
+
+	M_A_strict_match_expression_must_have_a_default_arm = &DiagnosticMessage{
+		Code:     1452,
+		Category: Error,
+		Message:  "a strict match expression must have a default arm '_'",
+	}
+
+	M_Numeric_literal_0_is_ambiguous_it_is_read_as_1 = &DiagnosticMessage{
+		Code:     1460,
+		Category: Warning,
+		Message:  "numeric literal '{0}' is ambiguous, it is read as {1}",
+	}
+
+	M_Percent_sign_after_0_is_read_as_a_percentage = &DiagnosticMessage{
+		Code:     1461,
+		Category: Warning,
+		Message:  "'%' after '{0}' is read as a percentage, write '{0} %' for a remainder operator",
+	}
+
+	M_Binary_digit_expected = &DiagnosticMessage{
+		Code:     1177,
+		Category: Error,
+		Message:  "binary digit expected",
+	}
+
+	M_Octal_digit_expected = &DiagnosticMessage{
+		Code:     1178,
+		Category: Error,
+		Message:  "octal digit expected",
+	}
+
+	M_Character_0_is_read_as_1 = &DiagnosticMessage{
+		Code:     1470,
+		Category: Information,
+		Message:  "character '{0}' is read as '{1}'",
+	}
+
+	M_Unterminated_date_literal = &DiagnosticMessage{
+		Code:     1480,
+		Category: Error,
+		Message:  "unterminated date literal",
+	}
+
+	M_Invalid_date_literal_0 = &DiagnosticMessage{
+		Code:     1481,
+		Category: Error,
+		Message:  "invalid date literal '{0}'",
+	}
+
+	M_Unexpected_parser_failure_0 = &DiagnosticMessage{
+		Code:     1499,
+		Category: Error,
+		Message:  "unexpected parser failure: {0}",
+	}
 )
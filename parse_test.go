@@ -1,6 +1,8 @@
 package formula
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -37,6 +39,41 @@ func TestSelectorExpression(t *testing.T) {
 	}
 }
 
+func TestElementAccessExpression(t *testing.T) {
+	data := []string{
+		"a[0]",
+		"a['unit price']",
+		"a[i][j]",
+		"a.b[-1].c",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+}
+
+func TestOptionalChainExpression(t *testing.T) {
+	data := []string{
+		"a?.b",
+		"a?.b?.c ?? '-'",
+		"a?.[0]",
+		"a?.b()",
+		"a?.5:1",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+}
+
 func TestCallExpression(t *testing.T) {
 	data := []string{
 		"a()",
@@ -69,6 +106,102 @@ func TestArrayLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestSpreadElement(t *testing.T) {
+	data := []string{
+		"[...a]",
+		"[...a, x, ...b]",
+		"f(...a)",
+		"f(a, ...rest, c)",
+		"f(a, rest...)",
+		"f(...[1, 2], ...b,)",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil && i != 5 {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+		if err == nil && i == 5 {
+			t.Errorf("[%d] trailing comma except error", i)
+			return
+		}
+	}
+}
+
+func TestArrowFunction(t *testing.T) {
+	data := []string{
+		"x => x * 2",
+		"() => 1",
+		"(acc, x) => acc + x",
+		"map(items, x => x.price * x.qty)",
+		"reduce(items, (a, b) => a + b, 0)",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+}
+
+func TestObjectLiteralExpression(t *testing.T) {
+	data := []string{
+		"{}",
+		"{ a: 1 }",
+		"{ name: x, 'total': a + b, 1: true, }",
+		"{ ...base, x: 1, y }",
+		"{ ok: false, message: 'error' }.message",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+}
+
+func TestTemplateExpression(t *testing.T) {
+	data := []string{
+		"``",
+		"`hello`",
+		"`订单${no}金额超出${limit}`",
+		"`${a}${b}`",
+		"`${ { a: `${b}` }.a }`",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+}
+
+func TestInExpression(t *testing.T) {
+	data := []string{
+		"a in b",
+		"a not in b",
+		"status in ['paid', 'shipped'] && 'vip' not in tags",
+		"a + 1 in b == true",
+		"not + in",
+		"not(a) in b",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+}
+
 func TestParenthesizedExpression(t *testing.T) {
 	data := []string{
 		"(1)",
@@ -139,3 +272,352 @@ func TestConditionalExpression(t *testing.T) {
 		}
 	}
 }
+
+func TestComments(t *testing.T) {
+	code, err := ParseSourceCode([]byte("// 单价\nprice * /* 数量 */ qty // 合计\n/* 多行\n注释 */"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	except := []string{"// 单价", "/* 数量 */", "// 合计", "/* 多行\n注释 */"}
+	if len(code.Comments) != len(except) {
+		t.Errorf("except %d comments but got %d", len(except), len(code.Comments))
+		return
+	}
+	for i, comment := range code.Comments {
+		if comment.Text != except[i] || string(code.Text[comment.Pos():comment.End()]) != except[i] {
+			t.Errorf("[%d] except %q but got %q", i, except[i], comment.Text)
+			return
+		}
+	}
+	if code.Comments[0].Kind != SK_SingleLineCommentTrivia || code.Comments[1].Kind != SK_MultiLineCommentTrivia {
+		t.Errorf("comment kind mismatch")
+		return
+	}
+
+	_, err = ParseSourceCode([]byte("a + /* b"))
+	if err == nil {
+		t.Errorf("unterminated comment except error")
+		return
+	}
+}
+
+func TestStatements(t *testing.T) {
+	data := []string{
+		"a; b",
+		"a\nb",
+		"let a = 1; a",
+		"let a = 1\nlet b = a + 1\nb;",
+		"let f = x => x + later\nlet later = 1\nf(1)",
+		"a +\nb",
+		"let",
+		"let + 1",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+
+	code, err := ParseSourceCode([]byte("let a = 1\na + 1;"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if code.Statements.Len() != 2 || !Is[*BlockExpression](code.Expression) {
+		t.Errorf("except a block expression of 2 statements")
+		return
+	}
+
+	errors := map[string]string{
+		"a b":                      "; expected",
+		"let a = 1; let a = 2":     "cannot redeclare block-scoped variable 'a'",
+		"let b = a; let a = 1":     "block-scoped variable 'a' used before its declaration",
+		"let a = a + 1":            "block-scoped variable 'a' used before its declaration",
+		"let a = { b }; let b = 1": "block-scoped variable 'b' used before its declaration",
+		"a; )":                     "declaration or statement expected",
+	}
+	for str, except := range errors {
+		_, err := ParseSourceCode([]byte(str))
+		if err == nil || !strings.Contains(err.Error(), except) {
+			t.Errorf("%s except error %q but got %v", str, except, err)
+			return
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	data := []string{
+		"match (a) { 'a' => 1, 'b', 'c' => 2, _ => 0 }",
+		"match (a) { 1 => 'x', }",
+		"match strict (a + 1) { 1 => x => x, _ => null }",
+		"match(a)",
+		"match(a, b) + 1",
+		"match.x",
+		"match (a) { x => 1, _ => match (b) { _ => 2 } }",
+	}
+
+	for i, str := range data {
+		_, err := ParseSourceCode([]byte(str))
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			return
+		}
+	}
+
+	code, err := ParseSourceCode([]byte("match (a) { 'a' => 1, 'b', 'c' => 2, _ => 0 }"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expr, ok := code.Expression.(*MatchExpression)
+	if !ok || expr.Arms.Len() != 3 || expr.Arms.At(1).Patterns.Len() != 2 || expr.Arms.At(2).Patterns.Len() != 0 {
+		t.Errorf("match expression arms mismatch")
+		return
+	}
+
+	errors := map[string]string{
+		"match strict (a) { 1 => 2 }":  "a strict match expression must have a default arm",
+		"match (a) { _ => 1, _ => 2 }": "a match expression can only have one default arm",
+		"match (a) { 1 => 2 3 => 4 }":  ", expected",
+		"match (a) { 1 }":              "=> expected",
+	}
+	for str, except := range errors {
+		_, err := ParseSourceCode([]byte(str))
+		if err == nil || !strings.Contains(err.Error(), except) {
+			t.Errorf("%s except error %q but got %v", str, except, err)
+			return
+		}
+	}
+}
+
+func TestNumberWarnings(t *testing.T) {
+	warnings := []struct {
+		locale NumberLocale
+		expr   string
+		except string
+	}{
+		{NL_Default, "10%-3", "'%' after '10' is read as a percentage"},
+		{NL_CommaGroup, "max(1,000, 2)", "numeric literal '1,000' is ambiguous, it is read as 1000"},
+		{NL_CommaDecimal, "max(1,5)", "numeric literal '1,5' is ambiguous, it is read as 1.5"},
+		{NL_CommaDecimal, "1.5", "numeric literal '1.5' is ambiguous, it is read as 1.5"},
+	}
+
+	for _, item := range warnings {
+		code, err := ParseSourceCode([]byte(item.expr), WithNumberLocale(item.locale), WithPercentLiterals())
+		if err != nil {
+			t.Errorf("%s %v", item.expr, err)
+			return
+		}
+		if len(code.Diagnostics) != 1 || code.Diagnostics[0].Category != Warning || !strings.Contains(code.Diagnostics[0].MessageText, item.except) {
+			t.Errorf("%s except warning %q but got %v", item.expr, item.except, code.Diagnostics)
+			return
+		}
+	}
+
+	for _, expr := range []string{"1,000.50", "15%", "10 % 3", "15%*2"} {
+		code, err := ParseSourceCode([]byte(expr), WithNumberLocale(NL_CommaGroup), WithPercentLiterals())
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if len(code.Diagnostics) != 0 {
+			t.Errorf("%s except no diagnostics but got %v", expr, code.Diagnostics)
+			return
+		}
+	}
+}
+
+func TestRadixLiteralErrors(t *testing.T) {
+	errorTests := map[string]string{
+		"0x":     "hexadecimal digit expected",
+		"0b":     "binary digit expected",
+		"0o":     "octal digit expected",
+		"0b1__0": "multiple consecutive numeric separators are not permitted",
+		"0o17_":  "numeric separators are not allowed here",
+		"0x1g":   "an identifier or keyword cannot immediately follow a numeric literal",
+		"0b12":   "expected",
+	}
+
+	for str, except := range errorTests {
+		_, err := ParseSourceCode([]byte(str))
+		if err == nil || !strings.Contains(err.Error(), except) {
+			t.Errorf("%s except error %q but got %v", str, except, err)
+			return
+		}
+	}
+}
+
+func TestFullWidthDiagnostics(t *testing.T) {
+	_, err := ParseSourceCode([]byte("max（1，2）"))
+	if err == nil || !strings.Contains(err.Error(), "invalid character") {
+		t.Errorf("except invalid character but got %v", err)
+		return
+	}
+
+	code, err := ParseSourceCode([]byte("max（1，“a，b”）"), WithFullWidthNormalization())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	excepts := []struct {
+		start int
+		text  string
+	}{
+		{3, "character '（' is read as '('"},
+		{7, "character '，' is read as ','"},
+		{10, "character '“' is read as '\"'"},
+		{18, "character '”' is read as '\"'"},
+		{21, "character '）' is read as ')'"},
+	}
+	if len(code.Diagnostics) != len(excepts) {
+		t.Errorf("except %d diagnostics but got %v", len(excepts), code.Diagnostics)
+		return
+	}
+	for i, except := range excepts {
+		diagnostic := code.Diagnostics[i]
+		if diagnostic.Category != Information || diagnostic.Start != except.start || diagnostic.Length != 3 || diagnostic.MessageText != except.text {
+			t.Errorf("except %q at %d but got %q at %d", except.text, except.start, diagnostic.MessageText, diagnostic.Start)
+			return
+		}
+	}
+
+	// only the delimiters of a comment are reported
+	code, err = ParseSourceCode([]byte("1 ／＊，＊／ ／／，"), WithFullWidthNormalization())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	starts := []int{2, 5, 11, 14, 18, 21}
+	if len(code.Diagnostics) != len(starts) {
+		t.Errorf("except %d diagnostics but got %v", len(starts), code.Diagnostics)
+		return
+	}
+	for i, start := range starts {
+		if code.Diagnostics[i].Start != start {
+			t.Errorf("except a diagnostic at %d but got %d", start, code.Diagnostics[i].Start)
+			return
+		}
+	}
+}
+
+func TestDateDurationLiteralErrors(t *testing.T) {
+	errorTests := map[string]string{
+		"#2024-13-01#": "invalid date literal '2024-13-01'",
+		"#tomorrow#":   "invalid date literal 'tomorrow'",
+		"#2024-05-01":  "unterminated date literal",
+		"3days":        "an identifier or keyword cannot immediately follow a numeric literal",
+		"2h30":         "expected",
+	}
+
+	for str, except := range errorTests {
+		_, err := ParseSourceCode([]byte(str))
+		if err == nil || !strings.Contains(err.Error(), except) {
+			t.Errorf("%s except error %q but got %v", str, except, err)
+			return
+		}
+	}
+}
+
+func TestParseSourceCodeWithDiagnostics(t *testing.T) {
+	tests := []struct {
+		code   string
+		except []string
+	}{
+		{"(a b) + c", []string{") expected"}},
+		{"x[1 2] * 2", []string{"] expected"}},
+		{"a ? b", []string{": expected"}},
+		{"match (a) { 1 => 2", []string{"} expected"}},
+		{"f(1,,2) + (x y; [1 2]", []string{"argument expression expected", ") expected", ", expected"}},
+		{"a b\n)\nlet $c = ", []string{"; expected", "declaration or statement expected", "expression excepted"}},
+		{"let @'\n*-", []string{"unterminated string literal", "identifier expected", "= expected", "expression excepted"}},
+	}
+
+	for _, test := range tests {
+		source := ParseSourceCodeWithDiagnostics([]byte(test.code))
+		if source == nil || source.Expression == nil {
+			t.Errorf("%s except source code", test.code)
+			return
+		}
+		if len(source.Diagnostics) != len(test.except) {
+			t.Errorf("%s except %d diagnostics but got %v", test.code, len(test.except), source.Diagnostics)
+			return
+		}
+		for i, except := range test.except {
+			if source.Diagnostics[i].MessageText != except {
+				t.Errorf("%s except %q but got %q", test.code, except, source.Diagnostics[i].MessageText)
+				return
+			}
+		}
+	}
+
+	// the skipped tokens don't break the rest of the expression
+	source := ParseSourceCodeWithDiagnostics([]byte("(a b) + c"))
+	binary, ok := source.Expression.(*BinaryExpression)
+	if !ok || binary.Operator.Token != SK_Plus {
+		t.Errorf("except binary expression but got %T", source.Expression)
+		return
+	}
+	paren, ok := binary.Left.(*ParenthesizedExpression)
+	if !ok || paren.Expression.(*Identifier).Value != "a" || binary.Right.(*Identifier).Value != "c" {
+		t.Errorf("except (a) + c")
+		return
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := ParseSourceCode([]byte("f(1,,2) + (x y"))
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Errorf("except *ParseError but got %T", err)
+		return
+	}
+	if len(parseError.Diagnostics) != 2 || parseError.Diagnostics[1].Start != 13 {
+		t.Errorf("except 2 diagnostics but got %v", parseError.Diagnostics)
+		return
+	}
+	if err.Error() != "pos(0, 4) error(1135) argument expression expected" {
+		t.Errorf("except the first error but got %s", err.Error())
+		return
+	}
+
+	// warnings are not errors
+	source, err := ParseSourceCode([]byte("10%-3"), WithPercentLiterals())
+	if err != nil || len(source.Diagnostics) != 1 {
+		t.Errorf("except a warning but got %v %v", err, source.Diagnostics)
+		return
+	}
+}
+
+// panicOnScanError makes the parser panic after the first scanner error, the scanner of the
+// token list has no error handler and isn't affected
+func panicOnScanError(s *Scanner) {
+	report := s.onError
+	if report == nil {
+		return
+	}
+	s.SetOnError(func(message *DiagnosticMessage, pos int, length int) {
+		report(message, pos, length)
+		panic("boom")
+	})
+}
+
+func TestRecoverSourceCode(t *testing.T) {
+	// the failure is reported at the invalid character, after the error of the character
+	source := ParseSourceCodeWithDiagnostics([]byte("a + \\"), panicOnScanError)
+	if len(source.Diagnostics) != 2 || source.Diagnostics[1].Code != M_Unexpected_parser_failure_0.Code {
+		t.Errorf("except the parser failure but got %v", source.Diagnostics)
+		return
+	}
+	if source.Diagnostics[1].Start != 4 || source.Diagnostics[1].MessageText != "unexpected parser failure: boom" {
+		t.Errorf("except boom at 4 but got %d %s", source.Diagnostics[1].Start, source.Diagnostics[1].MessageText)
+		return
+	}
+	if source.Expression == nil || source.Statements == nil {
+		t.Errorf("except an expression and statements after the failure")
+		return
+	}
+}
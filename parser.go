@@ -1,17 +1,21 @@
 package formula
 
 import (
-	"errors"
 	"fmt"
 	"runtime"
+	"strings"
 )
 
 type parsingContext = int
 
 const (
-	pcArgumentExpressions parsingContext = iota // Expressions in argument list
-	pcArrayLiteralMembers                       // Members in array literal
-	pcParsingContextCount                       // Number of parsing contexts
+	pcArgumentExpressions  parsingContext = iota // Expressions in argument list
+	pcArrayLiteralMembers                        // Members in array literal
+	pcParameters                                 // Parameters in arrow function
+	pcObjectLiteralMembers                       // Members in object literal
+	pcStatements                                 // Statements in program
+	pcMatchArms                                  // Arms in match expression
+	pcParsingContextCount                        // Number of parsing contexts
 )
 
 type Parser struct {
@@ -24,27 +28,50 @@ type Parser struct {
 	nodeCount       int
 	identifierCount int
 
-	parsingCtx parsingContext
+	parsingCtx  parsingContext
+	scanOptions []ScanOption
+
+	// Identifiers referenced outside of arrow functions, used to check block-scoped variables
+	identifierReferences []*Identifier
+	// Depth of arrow functions being parsed
+	arrowFunctionDepth int
 
 	// hasDeprecatedTag bool
 }
 
-func ParseSourceCode(content []byte) (source *SourceCode, err error) {
-	defer func() {
-		capture := recover()
-		if capture != nil {
-			switch err.(type) {
-			case runtime.Error:
-				err = errors.New("runtime error")
-			default:
-				err = fmt.Errorf("%v", capture)
-			}
+// ParseError is returned by ParseSourceCode when the formula has an error diagnostic,
+// Diagnostics holds all the diagnostics of the formula, warnings included.
+type ParseError struct {
+	Source      *SourceCode
+	Diagnostics []*Diagnostic
+}
+
+// Error formats the first error diagnostic.
+func (e *ParseError) Error() string {
+	for _, diagnostic := range e.Diagnostics {
+		if diagnostic.Category == Error {
+			return FormatDiagnostic(e.Source, diagnostic)
 		}
-		if source != nil && len(source.Diagnostics) > 0 {
-			err = errors.New(FormatDiagnostic(source, source.Diagnostics[0]))
+	}
+	return "no error"
+}
+
+// ParseSourceCode parses a formula, it fails with a *ParseError when there is an error diagnostic.
+// Warnings are left in SourceCode.Diagnostics.
+func ParseSourceCode(content []byte, opts ...ScanOption) (*SourceCode, error) {
+	var source = ParseSourceCodeWithDiagnostics(content, opts...)
+	for _, diagnostic := range source.Diagnostics {
+		if diagnostic.Category == Error {
+			return source, &ParseError{Source: source, Diagnostics: source.Diagnostics}
 		}
-	}()
+	}
+	return source, nil
+}
 
+// ParseSourceCodeWithDiagnostics always returns a SourceCode, all the diagnostics are in
+// SourceCode.Diagnostics. The parser recovers from missing and unexpected tokens, missing
+// nodes stand in for the wrong text, e.g. `(a b) + c` is read as `(a) + c`.
+func ParseSourceCodeWithDiagnostics(content []byte, opts ...ScanOption) (source *SourceCode) {
 	parser := &Parser{
 		sourceText:       content,
 		sourceCode:       nil,
@@ -52,9 +79,45 @@ func ParseSourceCode(content []byte) (source *SourceCode, err error) {
 		nodeCount:        0,
 		identifierCount:  0,
 		parsingCtx:       0,
+		scanOptions:      opts,
 	}
-	source = parser.parseSourceFileWorker(content)
-	return
+	defer func() {
+		if capture := recover(); capture != nil {
+			source = parser.recoverSourceCode(capture)
+		}
+	}()
+	return parser.parseSourceFileWorker(content)
+}
+
+// recoverSourceCode returns what has been parsed when the parser panics, the panic is
+// reported at the current token.
+func (p *Parser) recoverSourceCode(capture interface{}) *SourceCode {
+	if p.sourceCode == nil {
+		p.sourceCode = p.createSourceCode(p.sourceText)
+	}
+	var pos = 0
+	if p.scanner != nil {
+		pos = p.scanner.GetTokenPos()
+	}
+	if err, ok := capture.(runtime.Error); ok {
+		capture = err.Error()
+	}
+	// Not deduplicated, the failure must be reported even after an error at the same position
+	p.parseDiagnostics = append(p.parseDiagnostics, CreateFileDiagnostic(p.sourceCode, pos, 0, M_Unexpected_parser_failure_0, capture))
+	if p.sourceCode.Statements == nil {
+		p.sourceCode.Statements = new(NodeList[Statement])
+	}
+	if p.sourceCode.Expression == nil {
+		var node = new(Identifier)
+		node.SetPos(pos)
+		node.SetEnd(pos)
+		p.sourceCode.Expression = node
+	}
+	p.sourceCode.Diagnostics = p.parseDiagnostics
+	if p.scanner != nil && p.scanner.keepTrivia {
+		p.sourceCode.Tokens = scanTokens(p.sourceText, p.scanOptions)
+	}
+	return p.sourceCode
 }
 
 func (p *Parser) startPos() int {
@@ -84,17 +147,26 @@ func (p *Parser) nextTokenIsIdentifierOrKeywordOnSameLine() bool {
 
 func (p *Parser) parseSourceFileWorker(content []byte) *SourceCode {
 	p.sourceCode = p.createSourceCode(content)
-	p.scanner = CreateScanner(p.sourceText, p.scanError)
+	p.scanner = CreateScanner(p.sourceText, p.scanError, p.scanOptions...)
 	// Prime the scanner.
 	p.nextToken()
-	// parse expression list
-	p.sourceCode.Expression = p.parseExpression()
-	assertMsg(p.token() == SK_EndOfFile, fmt.Sprintf("End of file not reached, stop at %d(\"%s\")", p.scanner.pos, p.scanner.GetTokenText()))
+	// parse statement list
+	p.sourceCode.Statements = parseList(p, pcStatements, p.parseStatement)
+	p.sourceCode.Expression = p.createProgramExpression(p.sourceCode.Statements)
+	p.checkBlockScopedVariables(p.sourceCode.Statements)
 	p.sourceCode.EndOfFileToken = p.parseToken()
+	setParentNodes(p.sourceCode)
+	if p.sourceCode.Expression.Parent() == nil {
+		p.sourceCode.Expression.SetParent(p.sourceCode)
+	}
 	// 记录相关信息
 	p.sourceCode.NodeCount = p.nodeCount
 	p.sourceCode.IdentifierCount = p.identifierCount
 	p.sourceCode.Diagnostics = p.parseDiagnostics
+	p.sourceCode.Comments = p.scanner.GetComments()
+	if p.scanner.keepTrivia {
+		p.sourceCode.Tokens = scanTokens(p.sourceText, p.scanOptions)
+	}
 	return p.sourceCode
 }
 
@@ -125,6 +197,11 @@ func (p *Parser) errorAtPosition(start int, length int, message *DiagnosticMessa
 	}
 }
 
+func (p *Parser) errorAtNode(node Node, message *DiagnosticMessage, args ...interface{}) {
+	var start = SkipTrivia(p.sourceText, node.Pos())
+	p.errorAtPosition(start, node.End()-start, message, args...)
+}
+
 func (p *Parser) scanError(message *DiagnosticMessage, pos int, length int) {
 	if pos == -1 {
 		pos = p.scanner.GetTextPos()
@@ -153,6 +230,7 @@ func parserSpeculationHelper[T any](p *Parser, callback func() T, isLookAhead bo
 	// Keep track of the state we'll need to rollback to if lookahead fails (or if the
 	// caller asked us to always reset our state).
 	var saveSyntacticErrorsLength = len(p.parseDiagnostics)
+	var saveIdentifierReferencesLength = len(p.identifierReferences)
 
 	var result T
 	if isLookAhead {
@@ -165,6 +243,7 @@ func parserSpeculationHelper[T any](p *Parser, callback func() T, isLookAhead bo
 	// then unconditionally restore us to where we were.
 	if IsNull(result) || isLookAhead {
 		p.parseDiagnostics = p.parseDiagnostics[:saveSyntacticErrorsLength]
+		p.identifierReferences = p.identifierReferences[:saveIdentifierReferencesLength]
 	}
 
 	return result
@@ -206,6 +285,39 @@ func (p *Parser) parseExpected(kind SyntaxKind, diagnosticMessage *DiagnosticMes
 	return false
 }
 
+// parseExpectedClosing parses the closing token of a bracketed expression. When it is missing, the
+// tokens up to the matching closing token are skipped, so `(a b) + c` is still read as `(a) + c`.
+func (p *Parser) parseExpectedClosing(kind SyntaxKind) bool {
+	if p.parseExpected(kind, nil, true) {
+		return true
+	}
+	if lookAhead(p, func() bool { return p.skipToClosing(kind) }) {
+		p.skipToClosing(kind)
+		p.nextToken()
+	}
+	return false
+}
+
+// skipToClosing skips the tokens before the closing token kind of the current nesting level,
+// returns false when another closing token or the end of file is met first.
+func (p *Parser) skipToClosing(kind SyntaxKind) bool {
+	var depth = 0
+	for {
+		switch p.token() {
+		case SK_EndOfFile:
+			return false
+		case SK_OpenParen, SK_OpenBracket, SK_OpenBrace:
+			depth++
+		case SK_CloseParen, SK_CloseBracket, SK_CloseBrace:
+			if depth == 0 {
+				return p.token() == kind
+			}
+			depth--
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) got(t SyntaxKind) bool {
 	if p.token() == t {
 		p.nextToken()
@@ -227,9 +339,9 @@ func (p *Parser) wantToken(t SyntaxKind, reportAtCurrentPosition bool, diagnosti
 	}
 
 	if reportAtCurrentPosition {
-		p.errorAtPosition(p.startPos(), 0, diagnosticMessage, args)
+		p.errorAtPosition(p.startPos(), 0, diagnosticMessage, args...)
 	} else {
-		p.errorAtCurrentToken(diagnosticMessage, args)
+		p.errorAtCurrentToken(diagnosticMessage, args...)
 	}
 
 	// Missing token (pos == end)
@@ -270,9 +382,17 @@ func (p *Parser) parseIdentifier(diagnosticMessage *DiagnosticMessage) *Identifi
 func (p *Parser) isListElement(context parsingContext) bool {
 	switch context {
 	case pcArgumentExpressions:
-		return p.isStartOfExpression()
+		return p.token() == SK_DotDotDot || p.isStartOfExpression()
 	case pcArrayLiteralMembers:
-		return p.token() == SK_Comma || p.isStartOfExpression()
+		return p.token() == SK_Comma || p.token() == SK_DotDotDot || p.isStartOfExpression()
+	case pcParameters:
+		return p.token() == SK_Identifier
+	case pcObjectLiteralMembers:
+		return p.token() == SK_DotDotDot || p.isPropertyName()
+	case pcStatements:
+		return p.isStartOfExpression()
+	case pcMatchArms:
+		return p.isStartOfExpression()
 	}
 
 	panic("Non-exhaustive case in 'isListElement'.")
@@ -303,9 +423,13 @@ func (p *Parser) isListTerminator(kind parsingContext) bool {
 	switch kind {
 	case pcArgumentExpressions:
 		// Tokens other than ')' are here for better error recovery
-		return p.token() == SK_CloseParen || p.token() == SK_DotDotDot
+		return p.token() == SK_CloseParen
 	case pcArrayLiteralMembers:
 		return p.token() == SK_CloseBracket
+	case pcParameters:
+		return p.token() == SK_CloseParen
+	case pcObjectLiteralMembers, pcMatchArms:
+		return p.token() == SK_CloseBrace
 	}
 	return false
 }
@@ -315,13 +439,10 @@ func parseList[T Node](p *Parser, kind parsingContext, parseElement func() T) *N
 	saveParsingCtx := p.parsingCtx
 	p.parsingCtx |= 1 << kind
 
-	var list *NodeList[T]
+	var list = new(NodeList[T])
 	list.SetPos(p.getNodePos())
 	for !p.isListTerminator(kind) {
 		if p.isListElement(kind) {
-			if list == nil {
-				list = new(NodeList[T])
-			}
 			list.Add(parseElement())
 			continue
 		}
@@ -353,6 +474,14 @@ func parsingContextErrors(context parsingContext) *DiagnosticMessage {
 		return M_Argument_expression_expected
 	case pcArrayLiteralMembers:
 		return M_Expression_or_comma_expected
+	case pcParameters:
+		return M_Parameter_declaration_expected
+	case pcObjectLiteralMembers:
+		return M_Property_assignment_expected
+	case pcStatements:
+		return M_Declaration_or_statement_expected
+	case pcMatchArms:
+		return M_Match_arm_expected
 	}
 
 	panic(fmt.Sprintf("ParsingContext(%d) kind is unknown:", context))
@@ -456,6 +585,7 @@ func (p *Parser) parseLiteralExpressionRest(kind SyntaxKind) *LiteralExpression
 	var node = new(LiteralExpression)
 	node.Token = kind
 	node.Value = p.scanner.GetTokenValue()
+	node.Flags = p.scanner.GetTokenFlags() &^ TF_PrecedingLineBreak
 	p.nextToken()
 	return finishNode(p, node, pos)
 }
@@ -481,8 +611,13 @@ func (p *Parser) isStartOfLeftHandSideExpression() bool {
 		// SK_DoubleLiteral,
 		SK_NumberLiteral,
 		SK_StringLiteral,
+		SK_DateLiteral,
+		SK_DurationLiteral,
+		SK_NoSubstitutionTemplateLiteral,
+		SK_TemplateHead,
 		SK_OpenParen,
 		SK_OpenBracket,
+		SK_OpenBrace,
 		SK_Slash,
 		SK_Identifier:
 		return true
@@ -531,13 +666,90 @@ func (p *Parser) parseExpression() Expression {
 }
 
 func (p *Parser) parseAssignmentExpressionOrHigher() Expression {
+	if p.isStartOfArrowFunction() {
+		return p.parseArrowFunction()
+	}
 	var expr = p.parseBinaryExpression(0)
 	if p.token().IsAssignmentOperator() {
+		// A plain `=` to anything else is left to the runner
+		if p.token() != SK_Equals && !isLocalVariable(expr) {
+			p.errorAtNode(expr, M_The_left_hand_side_of_an_assignment_expression_must_be_a_local_variable)
+		}
 		return p.makeBinaryExpression(expr, p.parseToken(), p.parseAssignmentExpressionOrHigher())
 	}
 	return p.parseConditionalExpression(expr)
 }
 
+// Only `$` prefixed identifiers can be assigned
+func isLocalVariable(expr Expression) bool {
+	identifier, ok := expr.(*Identifier)
+	return ok && strings.HasPrefix(identifier.Value, "$")
+}
+
+// True if positioned at `x =>` or `(a, b) =>`
+func (p *Parser) isStartOfArrowFunction() bool {
+	switch p.token() {
+	case SK_Identifier:
+		return lookAhead(p, p.nextTokenIsEqualsGreaterThanOnSameLine)
+	case SK_OpenParen:
+		return lookAhead(p, p.nextTokensAreArrowFunctionParameters)
+	}
+	return false
+}
+
+func (p *Parser) nextTokenIsEqualsGreaterThanOnSameLine() bool {
+	p.nextToken()
+	return p.token() == SK_EqualsGreaterThan && !p.scanner.HasPrecedingLineBreak()
+}
+
+func (p *Parser) nextTokensAreArrowFunctionParameters() bool {
+	p.nextToken()
+	for p.token() != SK_CloseParen {
+		if p.token() != SK_Identifier {
+			return false
+		}
+		p.nextToken()
+		if !p.got(SK_Comma) && p.token() != SK_CloseParen {
+			return false
+		}
+	}
+	return p.nextTokenIsEqualsGreaterThanOnSameLine()
+}
+
+func (p *Parser) parseArrowFunction() *ArrowFunction {
+	var pos = p.getNodePos()
+	var node = new(ArrowFunction)
+	if p.got(SK_OpenParen) {
+		node.Parameters = parseDelimitedList(p, pcParameters, p.parseParameter, false)
+		p.want(SK_CloseParen)
+	} else {
+		node.Parameters = new(NodeList[*Identifier])
+		node.Parameters.SetPos(p.getNodePos())
+		node.Parameters.Add(p.parseParameter())
+		node.Parameters.SetEnd(p.getNodePos())
+	}
+	p.checkDuplicateParameters(node.Parameters)
+	p.want(SK_EqualsGreaterThan)
+	p.arrowFunctionDepth++
+	node.Body = p.parseAssignmentExpressionOrHigher()
+	p.arrowFunctionDepth--
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) parseParameter() *Identifier {
+	return p.parseIdentifier(M_Parameter_declaration_expected)
+}
+
+func (p *Parser) checkDuplicateParameters(params *NodeList[*Identifier]) {
+	var names = map[string]bool{}
+	for _, param := range params.Array() {
+		if names[param.Value] {
+			p.errorAtNode(param, M_Duplicate_identifier_0, param.Value)
+		}
+		names[param.Value] = true
+	}
+}
+
 func (p *Parser) parseConditionalExpression(leftOperand Expression) Expression {
 	// Note: we are passed in an expression which was produced from parseBinaryExpressionOrHigher.
 	var questionToken = p.gotToken(SK_Question)
@@ -579,56 +791,99 @@ func (p *Parser) parseBinaryExpressionRest(precedence int, leftOperand Expressio
 		//            ^token; leftOperand = b. Return b to the caller as a rightOperand
 		//      a - b * c
 		//            ^token; leftOperand = b. Return b * c to the caller as a rightOperand
-		var consumeCurrentOperator = newPrecedence > precedence
+		// - For right associative operator (**), consume the operator, recursively call the function
+		//   and parse binaryExpression as a rightOperand of the caller if the new precedence of
+		//   the operator is strictly grater than the current precedence
+		//   For example:
+		//      a ** b ** c;
+		//             ^^token; leftOperand = b. Return b ** c to the caller as a rightOperand
+		var consumeCurrentOperator bool
+		if p.token() == SK_AsteriskAsterisk {
+			consumeCurrentOperator = newPrecedence >= precedence
+		} else {
+			consumeCurrentOperator = newPrecedence > precedence
+		}
 
 		if !consumeCurrentOperator {
 			break
 		}
 
-		leftOperand = p.makeBinaryExpression(leftOperand, p.parseToken(), p.parseBinaryExpression(newPrecedence))
+		leftOperand = p.makeBinaryExpression(leftOperand, p.parseBinaryOperatorToken(), p.parseBinaryExpression(newPrecedence))
 	}
 
 	return leftOperand
 }
 
+// parseBinaryOperatorToken parses the operator, `not in` becomes a single SK_NotKeyword token.
+func (p *Parser) parseBinaryOperatorToken() *TokenNode {
+	var pos = p.getNodePos()
+	var node = new(TokenNode)
+	node.Token = p.token()
+	p.nextToken()
+	if node.Token == SK_NotKeyword {
+		p.want(SK_InKeyword)
+	}
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) nextTokenIsInKeyword() bool {
+	return p.nextToken() == SK_InKeyword
+}
+
 func (p *Parser) isBinaryOperator() bool {
 	return p.getBinaryOperatorPrecedence() > 0
 }
 
 func (p *Parser) getBinaryOperatorPrecedence() int {
-	switch p.token() {
+	// `not` is only an operator in `not in`
+	if p.token() == SK_NotKeyword && !lookAhead(p, p.nextTokenIsInKeyword) {
+		return -1
+	}
+	return getBinaryOperatorPrecedence(p.token())
+}
+
+// getBinaryOperatorPrecedence returns the precedence of a binary operator, higher binds tighter,
+// SK_NotKeyword stands for `not in`. It returns -1 for other tokens.
+func getBinaryOperatorPrecedence(kind SyntaxKind) int {
+	switch kind {
+	case SK_BarGreaterThan:
+		return 1
 	case SK_BarBar,
 		SK_QuestionQuestion:
-		return 1
-	case SK_AmpersandAmpersand:
 		return 2
-	case SK_Bar:
+	case SK_AmpersandAmpersand:
 		return 3
-	case SK_Caret:
+	case SK_Bar:
 		return 4
-	case SK_Ampersand:
+	case SK_Caret:
 		return 5
+	case SK_Ampersand:
+		return 6
 	case SK_EqualsEquals,
 		SK_EqualsEqualsEquals,
 		SK_ExclamationEquals,
 		SK_ExclamationEqualsEquals:
-		return 6
+		return 7
 	case SK_LessThan,
 		SK_GreaterThan,
 		SK_LessThanEquals,
-		SK_GreaterThanEquals:
-		return 7
-	// case SK_LessThanLessThan,
-	// 	SK_GreaterThanGreaterThan,
-	// 	SK_GreaterThanGreaterThanGreaterThan:
-	// 	return 8
+		SK_GreaterThanEquals,
+		SK_InKeyword,
+		SK_NotKeyword:
+		return 8
+	case SK_LessThanLessThan,
+		SK_GreaterThanGreaterThan,
+		SK_GreaterThanGreaterThanGreaterThan:
+		return 9
 	case SK_Plus,
 		SK_Minus:
-		return 9
+		return 10
 	case SK_Asterisk,
 		SK_Slash,
 		SK_Percent:
-		return 10
+		return 11
+	case SK_AsteriskAsterisk:
+		return 12
 	}
 
 	// -1 is lower than all other precedences.  Returning it will cause binary expression
@@ -661,7 +916,17 @@ func (p *Parser) parseTypeOfExpression() *TypeOfExpression {
 }
 
 func (p *Parser) parseUnaryExpression() Expression {
-	return p.parseSimpleUnaryExpression()
+	var expression = p.parseSimpleUnaryExpression()
+	// `-2 ** 2` is ambiguous, it must be written as `(-2) ** 2` or `-(2 ** 2)`
+	if p.token() == SK_AsteriskAsterisk {
+		switch n := expression.(type) {
+		case *PrefixUnaryExpression:
+			p.errorAtNode(n, M_An_unary_expression_with_the_0_operator_is_not_allowed_in_the_left_hand_side_of_an_exponentiation_expression, n.Operator.Token.ToString())
+		case *TypeOfExpression:
+			p.errorAtNode(n, M_An_unary_expression_with_the_0_operator_is_not_allowed_in_the_left_hand_side_of_an_exponentiation_expression, SK_TypeofKeyword.ToString())
+		}
+	}
+	return expression
 }
 
 // Parse simple-unary expression or higher:
@@ -702,23 +967,55 @@ func (p *Parser) parseMemberExpressionRest(expr Expression) Expression {
 			break
 		}
 
-		dotToken := p.gotToken(SK_Dot)
-		exclamationDot := p.gotToken(SK_ExclamationDot)
-		if dotToken != nil || exclamationDot != nil {
+		questionDot := p.gotToken(SK_QuestionDot)
+		if questionDot != nil && p.token() == SK_OpenBracket {
+			expr = p.parseElementAccessExpressionRest(expr, true)
+			continue
+		}
+
+		var dotToken, exclamationDot *TokenNode
+		if questionDot == nil {
+			dotToken = p.gotToken(SK_Dot)
+			exclamationDot = p.gotToken(SK_ExclamationDot)
+		}
+		if dotToken != nil || exclamationDot != nil || questionDot != nil {
 			var node = new(SelectorExpression)
 			node.Expression = expr
 			node.Name = p.parseRightSideOfDot()
 			node.Assert = exclamationDot != nil
+			node.Optional = questionDot != nil
 			expr = finishNode(p, node, expr.Pos())
 			continue
 		}
 
+		if p.token() == SK_OpenBracket {
+			expr = p.parseElementAccessExpressionRest(expr, false)
+			continue
+		}
+
 		break
 	}
 
 	return expr
 }
 
+func (p *Parser) parseElementAccessExpressionRest(expr Expression, optional bool) *ElementAccessExpression {
+	var node = new(ElementAccessExpression)
+	node.Expression = expr
+	node.Optional = optional
+	p.want(SK_OpenBracket)
+	if p.token() == SK_CloseBracket {
+		p.errorAtCurrentToken(M_An_element_access_expression_should_take_an_argument)
+		var argument = new(Identifier)
+		p.fillMissPos(argument)
+		node.ArgumentExpression = argument
+	} else {
+		node.ArgumentExpression = p.parseExpression()
+	}
+	p.parseExpectedClosing(SK_CloseBracket)
+	return finishNode(p, node, expr.Pos())
+}
+
 func (p *Parser) parseCallExpressionRest(expr Expression) Expression {
 	for {
 		// Must on same line
@@ -730,7 +1027,7 @@ func (p *Parser) parseCallExpressionRest(expr Expression) Expression {
 		if p.token() == SK_OpenParen {
 			var callExpr = new(CallExpression)
 			callExpr.Expression = expr
-			callExpr.Arguments, callExpr.DotDotDotToken = p.parseArgumentList()
+			callExpr.Arguments = p.parseArgumentList()
 			expr = finishNode(p, callExpr, expr.Pos())
 			continue
 		}
@@ -741,23 +1038,21 @@ func (p *Parser) parseCallExpressionRest(expr Expression) Expression {
 	return expr
 }
 
-func (p *Parser) parseArgumentList() (*NodeList[Expression], *TokenNode) {
+func (p *Parser) parseArgumentList() *NodeList[Expression] {
 	if !p.want(SK_OpenParen) {
-		return nil, nil
+		return nil
 	}
 	var list = parseDelimitedList(p, pcArgumentExpressions, p.parseArgumentExpression, false)
-	var token *TokenNode
-	if p.token() == SK_DotDotDot {
-		token = p.parseToken()
-	}
 	p.want(SK_CloseParen)
-	return list, token
+	return list
 }
 
 func (p *Parser) parsePrimaryExpression() Expression {
 	switch p.token() {
 	case SK_NumberLiteral,
 		SK_StringLiteral,
+		SK_DateLiteral,
+		SK_DurationLiteral,
 		SK_NullKeyword,
 		SK_TrueKeyword,
 		SK_FalseKeyword,
@@ -768,9 +1063,93 @@ func (p *Parser) parsePrimaryExpression() Expression {
 		return p.parseParenthesizedExpression()
 	case SK_OpenBracket:
 		return p.parseArrayLiteralExpression()
+	case SK_OpenBrace:
+		return p.parseObjectLiteralExpression()
+	case SK_NoSubstitutionTemplateLiteral, SK_TemplateHead:
+		return p.parseTemplateExpression()
+	}
+
+	if p.isContextualKeyword("match") {
+		if expr := tryParse(p, p.parseMatchExpressionHead); expr != nil {
+			return p.parseMatchExpressionRest(expr)
+		}
 	}
 
-	return p.parseIdentifier(M_Expression_expected)
+	var identifier = p.parseIdentifier(M_Expression_expected)
+	p.recordIdentifierReference(identifier)
+	return identifier
+}
+
+// `match` and `strict` are only keywords in a match expression, they can still be used as names
+func (p *Parser) isContextualKeyword(value string) bool {
+	return p.token() == SK_Identifier && p.scanner.GetTokenValue() == value && p.scanner.GetTokenFlags()&TF_QuotedIdentifier == 0
+}
+
+// Parses `match [strict] (Expression) {`, returns nil when it is not a match expression, e.g. a call `match(a)`
+func (p *Parser) parseMatchExpressionHead() *MatchExpression {
+	var pos = p.getNodePos()
+	var node = new(MatchExpression)
+	p.nextToken()
+	if p.isContextualKeyword("strict") {
+		node.Strict = true
+		p.nextToken()
+	}
+	if !p.got(SK_OpenParen) {
+		return nil
+	}
+	node.Expression = p.parseExpression()
+	if !p.got(SK_CloseParen) || p.token() != SK_OpenBrace {
+		return nil
+	}
+	node.SetPos(pos)
+	return node
+}
+
+func (p *Parser) parseMatchExpressionRest(node *MatchExpression) *MatchExpression {
+	var pos = node.Pos()
+	p.want(SK_OpenBrace)
+	node.Arms = parseDelimitedList(p, pcMatchArms, p.parseMatchArm, true)
+	p.want(SK_CloseBrace)
+	finishNode(p, node, pos)
+
+	var hasDefault = false
+	for _, arm := range node.Arms.Array() {
+		if arm.Patterns.Len() == 0 {
+			if hasDefault {
+				p.errorAtNode(arm, M_A_match_expression_can_only_have_one_default_arm)
+			}
+			hasDefault = true
+		}
+	}
+	if node.Strict && !hasDefault {
+		p.errorAtNode(node, M_A_strict_match_expression_must_have_a_default_arm)
+	}
+	return node
+}
+
+func (p *Parser) parseMatchArm() *MatchArm {
+	var pos = p.getNodePos()
+	var node = new(MatchArm)
+	node.Patterns = new(NodeList[Expression])
+	node.Patterns.SetPos(pos)
+	// `_ =>` is the default arm
+	if p.isContextualKeyword("_") && lookAhead(p, p.nextTokenIsEqualsGreaterThan) {
+		p.nextToken()
+	} else {
+		// Patterns can't be arrow functions or conditionals, `=>` ends them
+		node.Patterns.Add(p.parseBinaryExpression(0))
+		for p.got(SK_Comma) {
+			node.Patterns.Add(p.parseBinaryExpression(0))
+		}
+	}
+	node.Patterns.SetEnd(p.getNodePos())
+	p.want(SK_EqualsGreaterThan)
+	node.Result = p.parseAssignmentExpressionOrHigher()
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) nextTokenIsEqualsGreaterThan() bool {
+	return p.nextToken() == SK_EqualsGreaterThan
 }
 
 func (p *Parser) parseParenthesizedExpression() *ParenthesizedExpression {
@@ -778,16 +1157,35 @@ func (p *Parser) parseParenthesizedExpression() *ParenthesizedExpression {
 	var node = new(ParenthesizedExpression)
 	p.want(SK_OpenParen)
 	node.Expression = p.parseExpression()
-	p.want(SK_CloseParen)
+	p.parseExpectedClosing(SK_CloseParen)
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) parseSpreadElement() *SpreadElement {
+	var pos = p.getNodePos()
+	var node = new(SpreadElement)
+	p.want(SK_DotDotDot)
+	node.Expression = p.parseAssignmentExpressionOrHigher()
 	return finishNode(p, node, pos)
 }
 
 func (p *Parser) parseArgumentOrArrayLiteralElement() Expression {
+	if p.token() == SK_DotDotDot {
+		return p.parseSpreadElement()
+	}
 	return p.parseAssignmentExpressionOrHigher()
 }
 
 func (p *Parser) parseArgumentExpression() Expression {
-	return p.parseArgumentOrArrayLiteralElement()
+	var expr = p.parseArgumentOrArrayLiteralElement()
+	// `f(arr...)` is the same as `f(...arr)`
+	if p.token() == SK_DotDotDot && !Is[*SpreadElement](expr) {
+		var node = new(SpreadElement)
+		node.Expression = expr
+		p.nextToken()
+		return finishNode(p, node, expr.Pos())
+	}
+	return expr
 }
 
 func (p *Parser) parseArrayLiteralExpression() *ArrayLiteralExpression {
@@ -799,3 +1197,164 @@ func (p *Parser) parseArrayLiteralExpression() *ArrayLiteralExpression {
 	p.want(SK_CloseBracket)
 	return finishNode(p, node, pos)
 }
+
+func (p *Parser) parseObjectLiteralExpression() *ObjectLiteralExpression {
+	var pos = p.getNodePos()
+	var node = new(ObjectLiteralExpression)
+	p.want(SK_OpenBrace)
+	node.Properties = parseDelimitedList(p, pcObjectLiteralMembers, p.parseObjectLiteralElement, true)
+	p.want(SK_CloseBrace)
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) isPropertyName() bool {
+	return p.token() == SK_StringLiteral || p.token() == SK_NumberLiteral || p.token().IsIdentifier()
+}
+
+func (p *Parser) parseObjectLiteralElement() ObjectLiteralElement {
+	var pos = p.getNodePos()
+	if p.got(SK_DotDotDot) {
+		var node = new(SpreadAssignment)
+		node.Expression = p.parseAssignmentExpressionOrHigher()
+		return finishNode(p, node, pos)
+	}
+
+	var name Expression
+	if p.token() == SK_StringLiteral || p.token() == SK_NumberLiteral {
+		name = p.parseLiteralExpression()
+	} else {
+		name = p.parseIdentifier(M_Property_assignment_expected)
+	}
+	// { name } is short for { name: name }
+	if identifier, ok := name.(*Identifier); ok && identifier.OriginalToken == SK_Identifier && p.token() != SK_Colon {
+		p.recordIdentifierReference(identifier)
+		var node = new(ShorthandPropertyAssignment)
+		node.Name = identifier
+		return finishNode(p, node, pos)
+	}
+
+	var node = new(PropertyAssignment)
+	node.Name = name
+	p.want(SK_Colon)
+	node.Initializer = p.parseAssignmentExpressionOrHigher()
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) parseTemplateExpression() *TemplateExpression {
+	var pos = p.getNodePos()
+	var node = new(TemplateExpression)
+	var isHead = p.token() == SK_TemplateHead
+	node.Head = p.scanner.GetTokenValue()
+	p.nextToken()
+	node.Spans = new(NodeList[*TemplateSpan])
+	node.Spans.SetPos(p.getNodePos())
+	for isHead {
+		var span, isTail = p.parseTemplateSpan()
+		node.Spans.Add(span)
+		isHead = !isTail
+	}
+	node.Spans.SetEnd(p.getNodePos())
+	return finishNode(p, node, pos)
+}
+
+// Parses `Expression}Literal${` or `Expression}Literal“, and reports whether it is the tail.
+func (p *Parser) parseTemplateSpan() (*TemplateSpan, bool) {
+	var pos = p.getNodePos()
+	var node = new(TemplateSpan)
+	node.Expression = p.parseExpression()
+	if p.token() != SK_CloseBrace {
+		p.errorAtCurrentToken(M_0_expected, SK_CloseBrace.ToString())
+		return finishNode(p, node, pos), true
+	}
+	var token = p.scanner.ReScanTemplateToken()
+	node.Literal = p.scanner.GetTokenValue()
+	p.nextToken()
+	return finishNode(p, node, pos), token == SK_TemplateTail
+}
+
+// STATEMENTS
+
+func (p *Parser) parseStatement() Statement {
+	if p.token() == SK_LetKeyword && lookAhead(p, p.nextTokenIsIdentifierOrKeywordOnSameLine) {
+		return p.parseVariableStatement()
+	}
+	return p.parseExpressionStatement()
+}
+
+func (p *Parser) parseVariableStatement() *VariableStatement {
+	var pos = p.getNodePos()
+	var node = new(VariableStatement)
+	p.want(SK_LetKeyword)
+	node.Name = p.parseIdentifier(nil)
+	p.want(SK_Equals)
+	node.Initializer = p.parseAssignmentExpressionOrHigher()
+	p.parseSemicolon()
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) parseExpressionStatement() *ExpressionStatement {
+	var pos = p.getNodePos()
+	var node = new(ExpressionStatement)
+	node.Expression = p.parseExpression()
+	p.parseSemicolon()
+	return finishNode(p, node, pos)
+}
+
+func (p *Parser) canParseSemicolon() bool {
+	// If there's a real semicolon, then we can always parse it out.
+	if p.token() == SK_Semicolon {
+		return true
+	}
+	// We can parse out an optional semicolon in ASI cases in the following cases.
+	return p.token() == SK_EndOfFile || p.scanner.HasPrecedingLineBreak()
+}
+
+func (p *Parser) parseSemicolon() bool {
+	if p.canParseSemicolon() {
+		p.got(SK_Semicolon)
+		return true
+	}
+	return p.want(SK_Semicolon)
+}
+
+// createProgramExpression keeps a single expression program as it is, other programs become a BlockExpression.
+func (p *Parser) createProgramExpression(statements *NodeList[Statement]) Expression {
+	if statements.Len() == 0 {
+		return p.parseIdentifier(M_Expression_expected)
+	}
+	if statement, ok := statements.At(0).(*ExpressionStatement); ok && statements.Len() == 1 {
+		return statement.Expression
+	}
+	var node = new(BlockExpression)
+	node.Statements = statements
+	return finishNode(p, node, statements.Pos(), statements.End())
+}
+
+func (p *Parser) recordIdentifierReference(identifier *Identifier) {
+	// Arrow function bodies run later, they may reference variables declared after them.
+	if p.arrowFunctionDepth == 0 {
+		p.identifierReferences = append(p.identifierReferences, identifier)
+	}
+}
+
+func (p *Parser) checkBlockScopedVariables(statements *NodeList[Statement]) {
+	var declarations = map[string]*VariableStatement{}
+	for _, statement := range statements.Array() {
+		// Missing names have an empty value
+		if n, ok := statement.(*VariableStatement); ok && n.Name.Value != "" {
+			if _, ok := declarations[n.Name.Value]; ok {
+				p.errorAtNode(n.Name, M_Cannot_redeclare_block_scoped_variable_0, n.Name.Value)
+				continue
+			}
+			declarations[n.Name.Value] = n
+		}
+	}
+	for _, identifier := range p.identifierReferences {
+		if identifier.Value == "" {
+			continue
+		}
+		if declaration, ok := declarations[identifier.Value]; ok && identifier.Pos() < declaration.End() {
+			p.errorAtNode(identifier, M_Block_scoped_variable_0_used_before_its_declaration, identifier.Value)
+		}
+	}
+}
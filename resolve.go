@@ -30,6 +30,17 @@ func ResolveReferenceFieldsNotLocal(source *SourceCode) ([]string, error) {
 
 type referenceResovle struct {
 	fields []string
+	// local names of the enclosing arrow functions, they are not fields
+	locals []map[string]bool
+}
+
+func (r *referenceResovle) isLocal(name string) bool {
+	for _, names := range r.locals {
+		if names[name] {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *referenceResovle) resolve(node Node) error {
@@ -42,25 +53,41 @@ func (r *referenceResovle) resolve(node Node) error {
 		return r.resolveBinaryExpression(n)
 	case *ArrayLiteralExpression:
 		return r.resolveArrayLiteralExpression(n)
+	case *ObjectLiteralExpression:
+		return r.resolveObjectLiteralExpression(n)
+	case *TemplateExpression:
+		return r.resolveTemplateExpression(n)
 	case *ParenthesizedExpression:
 		return r.resolveParenthesizedExpression(n)
 	case *LiteralExpression:
 		return r.resolveLiteralExpression(n)
 	case *SelectorExpression:
 		return r.resolveSelectorExpression(n)
+	case *ElementAccessExpression:
+		return r.resolveElementAccessExpression(n)
 	case *CallExpression:
 		return r.resolveCallExpression(n)
 	case *ConditionalExpression:
 		return r.resolveConditionalExpression(n)
+	case *MatchExpression:
+		return r.resolveMatchExpression(n)
 	case *TypeOfExpression:
 		return r.resolveTypeofExpression(n)
+	case *ArrowFunction:
+		return r.resolveArrowFunction(n)
+	case *BlockExpression:
+		return r.resolveBlockExpression(n)
+	case *SpreadElement:
+		return r.resolve(n.Expression)
 	default:
 		return errors.New("unknown expression type")
 	}
 }
 
 func (r *referenceResovle) resolveIdentifier(v *Identifier) error {
-	r.fields = append(r.fields, v.Value)
+	if !r.isLocal(v.Value) {
+		r.fields = append(r.fields, v.Value)
+	}
 	return nil
 }
 
@@ -73,6 +100,11 @@ func (r *referenceResovle) resolveBinaryExpression(v *BinaryExpression) error {
 	if err != nil {
 		return err
 	}
+	// `x |> trim` is `trim(x)` and `x |> obj.method` is `obj.method(x)`, like in a call the
+	// function isn't a field
+	if _, ok := v.Right.(*CallExpression); !ok && v.Operator.Token == SK_BarGreaterThan {
+		return nil
+	}
 	err = r.resolve(v.Right)
 	if err != nil {
 		return err
@@ -92,6 +124,54 @@ func (r *referenceResovle) resolveArrayLiteralExpression(v *ArrayLiteralExpressi
 	return nil
 }
 
+func (r *referenceResovle) resolveObjectLiteralExpression(v *ObjectLiteralExpression) error {
+	for _, property := range v.Properties.Array() {
+		var err error
+		switch n := property.(type) {
+		case *PropertyAssignment:
+			err = r.resolve(n.Initializer)
+		case *ShorthandPropertyAssignment:
+			err = r.resolveIdentifier(n.Name)
+		case *SpreadAssignment:
+			err = r.resolve(n.Expression)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *referenceResovle) resolveMatchExpression(v *MatchExpression) error {
+	err := r.resolve(v.Expression)
+	if err != nil {
+		return err
+	}
+	for _, arm := range v.Arms.Array() {
+		for _, pattern := range arm.Patterns.Array() {
+			err = r.resolve(pattern)
+			if err != nil {
+				return err
+			}
+		}
+		err = r.resolve(arm.Result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *referenceResovle) resolveTemplateExpression(v *TemplateExpression) error {
+	for _, span := range v.Spans.Array() {
+		err := r.resolve(span.Expression)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *referenceResovle) resolveParenthesizedExpression(v *ParenthesizedExpression) error {
 	return r.resolve(v.Expression)
 }
@@ -101,14 +181,53 @@ func (r *referenceResovle) resolveLiteralExpression(v Expression) error {
 }
 
 func (r *referenceResovle) resolveSelectorExpression(v *SelectorExpression) error {
-	names, err := resolveSelecotrNames(v)
+	return r.resolveAccessExpression(v)
+}
+
+func (r *referenceResovle) resolveElementAccessExpression(v *ElementAccessExpression) error {
+	return r.resolveAccessExpression(v)
+}
+
+// resolveAccessExpression reports the field path of a selector or element access chain,
+// e.g. `row['unit price'].value` is reported as `row.unit price.value`.
+func (r *referenceResovle) resolveAccessExpression(v Expression) error {
+	names, _, err := r.resolveAccessPath(v)
 	if err != nil {
 		return err
 	}
-	r.fields = append(r.fields, strings.Join(names, "."))
+	if len(names) > 0 && !r.isLocal(names[0]) {
+		r.fields = append(r.fields, strings.Join(names, "."))
+	}
 	return nil
 }
 
+// resolveAccessPath returns the field path of expr and whether the path can still be
+// extended by an outer selector. A computed key such as `items[i]` ends the path; the
+// key expression is then resolved on its own.
+func (r *referenceResovle) resolveAccessPath(expr Expression) ([]string, bool, error) {
+	switch n := expr.(type) {
+	case *Identifier:
+		return []string{n.Value}, true, nil
+	case *SelectorExpression:
+		names, open, err := r.resolveAccessPath(n.Expression)
+		if err != nil || !open {
+			return names, false, err
+		}
+		return append(names, n.Name.Value), true, nil
+	case *ElementAccessExpression:
+		names, open, err := r.resolveAccessPath(n.Expression)
+		if err != nil {
+			return nil, false, err
+		}
+		if literal, ok := n.ArgumentExpression.(*LiteralExpression); ok && open && literal.Token == SK_StringLiteral {
+			return append(names, literal.Value), true, nil
+		}
+		return names, false, r.resolve(n.ArgumentExpression)
+	default:
+		return nil, false, r.resolve(expr)
+	}
+}
+
 func (r *referenceResovle) resolveCallExpression(v *CallExpression) error {
 	if v.Arguments != nil && v.Arguments.Len() > 0 {
 		for i := 0; i < v.Arguments.Len(); i++ {
@@ -144,3 +263,37 @@ func (r *referenceResovle) resolveTypeofExpression(v *TypeOfExpression) error {
 	}
 	return nil
 }
+
+func (r *referenceResovle) resolveBlockExpression(v *BlockExpression) error {
+	names := map[string]bool{}
+	for _, statement := range v.Statements.Array() {
+		if n, ok := statement.(*VariableStatement); ok {
+			names[n.Name.Value] = true
+		}
+	}
+	r.locals = append(r.locals, names)
+	defer func() { r.locals = r.locals[:len(r.locals)-1] }()
+	for _, statement := range v.Statements.Array() {
+		var err error
+		switch n := statement.(type) {
+		case *VariableStatement:
+			err = r.resolve(n.Initializer)
+		case *ExpressionStatement:
+			err = r.resolve(n.Expression)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *referenceResovle) resolveArrowFunction(v *ArrowFunction) error {
+	names := map[string]bool{}
+	for _, param := range v.Parameters.Array() {
+		names[param.Value] = true
+	}
+	r.locals = append(r.locals, names)
+	defer func() { r.locals = r.locals[:len(r.locals)-1] }()
+	return r.resolve(v.Body)
+}
@@ -10,6 +10,18 @@ func TestResolve(t *testing.T) {
 	examples := map[string][]string{
 		"person.name + person.age + lala + run(a, b, c, d)":                                {"person.name", "person.age", "lala", "a", "b", "c", "d"},
 		"age !== null ? '' : ($1=(name==='刚子'&&'刚子的年龄是必填的'),typeof $1 === 'string'?$1:'')": {"age", "name", "$1"},
+		"row['unit price'] * row.qty":                                                      {"row.unit price", "row.qty"},
+		"matrix[i][j] + items[0].name":                                                     {"matrix", "i", "j", "items"},
+		"reduce(map(lines, x => x.price * x.qty), (acc, x) => acc + x * rate, 0)":          {"lines", "rate"},
+		"{ ...base, ok: a > b, message, 'total': c.d }":                                    {"base", "a", "b", "message", "c.d"},
+		"`订单${no}金额超出${toString(order.limit)}`":                                            {"no", "order.limit"},
+		"let total = price * qty; let rate = 0.1\ntotal * rate + fee":                      {"price", "qty", "fee"},
+		"match (status) { 1 => a, b, c => d, _ => e.f }":                                   {"status", "a", "b", "c", "d", "e.f"},
+		"@'Order Total' * 2 + @\"2023-amount\" + row.@'unit price'":                        {"Order Total", "2023-amount", "row.unit price"},
+		"x |> upper |> lower":                                                              {"x"},
+		"x |> obj.method":                                                                  {"x"},
+		"x |> obj.method(y) |> upper":                                                      {"x", "y"},
+		"nums |> map(x => x * k) |> join(sep)":                                             {"nums", "k", "sep"},
 	}
 
 	for formula, except := range examples {
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -75,6 +77,14 @@ func init() {
 	// UTILITIES
 	innerMap.Store("mapToArr", funMapToArr)
 	innerMap.Store("join", funJoin)
+	// FUNCTION ARRAY
+	innerMap.Store("map", funMap)
+	innerMap.Store("filter", funFilter)
+	innerMap.Store("reduce", funReduce)
+	innerMap.Store("some", funSome)
+	innerMap.Store("every", funEvery)
+	innerMap.Store("sortBy", funSortBy)
+	innerMap.Store("groupBy", funGroupBy)
 	// CONV
 	innerMap.Store("toString", funToString)
 	innerMap.Store("toInt", funToInt)
@@ -99,6 +109,59 @@ func RunnerFromCtx(ctx context.Context) *Runner {
 type Runner struct {
 	this  map[string]interface{}
 	value map[string]interface{}
+	scope *scope
+}
+
+// scope holds the local names of a lexical block, e.g. the parameters of an arrow function.
+type scope struct {
+	values map[string]interface{}
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		values: map[string]interface{}{},
+		parent: parent,
+	}
+}
+
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for c := s; c != nil; c = c.parent {
+		if v, ok := c.values[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Lambda is the value of an arrow function expression. Functions receive it
+// through a *Lambda parameter and invoke it with Call.
+type Lambda struct {
+	runner *Runner
+	scope  *scope
+	expr   *ArrowFunction
+	// body is the compiled body of a lambda made by a Program, see Compile
+	body evalFunc
+}
+
+// Call evaluates the lambda body with args bound to its parameters.
+// Missing arguments are null and extra arguments are ignored.
+func (l *Lambda) Call(ctx context.Context, args ...interface{}) (interface{}, error) {
+	local := newScope(l.scope)
+	for i, param := range l.expr.Parameters.Array() {
+		var v interface{}
+		if i < len(args) {
+			v = args[i]
+		}
+		local.values[param.Value] = v
+	}
+	saved := l.runner.scope
+	l.runner.scope = local
+	defer func() { l.runner.scope = saved }()
+	if l.body != nil {
+		return l.body(ctx, l.runner)
+	}
+	return l.runner.resolve(ctx, l.expr.Body)
 }
 
 func (r *Runner) SetThis(m map[string]interface{}) {
@@ -138,18 +201,26 @@ func (r *Runner) resolve(ctx context.Context, v Expression) (res interface{}, er
 		res, err = r.resolveBinaryExpression(ctx, n)
 	case *ArrayLiteralExpression:
 		res, err = r.resolveArrayLiteralExpression(ctx, n)
+	case *ObjectLiteralExpression:
+		res, err = r.resolveObjectLiteralExpression(ctx, n)
+	case *TemplateExpression:
+		res, err = r.resolveTemplateExpression(ctx, n)
 	case *ParenthesizedExpression:
 		res, err = r.resolveParenthesizedExpression(ctx, n)
 	case *LiteralExpression:
 		res, err = r.resolveLiteralExpression(ctx, n)
-	case *SelectorExpression:
-		res, err = r.resolveSelectorExpression(ctx, n)
-	case *CallExpression:
-		res, err = r.resolveCallExpression(ctx, n)
+	case *SelectorExpression, *ElementAccessExpression, *CallExpression:
+		res, _, err = r.resolveChainExpression(ctx, n)
 	case *ConditionalExpression:
 		res, err = r.resolveConditionalExpression(ctx, n)
+	case *MatchExpression:
+		res, err = r.resolveMatchExpression(ctx, n)
 	case *TypeOfExpression:
 		res, err = r.resolveTypeofExpression(ctx, n)
+	case *ArrowFunction:
+		res, err = r.resolveArrowFunction(ctx, n)
+	case *BlockExpression:
+		res, err = r.resolveBlockExpression(ctx, n)
 	default:
 		return nil, errors.New("unknown expression type")
 	}
@@ -192,17 +263,54 @@ func formatInput(v interface{}) (interface{}, error) {
 }
 
 func (r *Runner) resolveIdentifier(ctx context.Context, expr *Identifier) (interface{}, error) {
+	if v, ok := r.scope.lookup(expr.Value); ok {
+		return v, nil
+	}
 	if v, ok := innerMap.Load(expr.Value); ok {
 		return v, nil
 	}
 	return r.this[expr.Value], nil
 }
 
-func (r *Runner) resolveSelectorExpression(ctx context.Context, expr *SelectorExpression) (interface{}, error) {
-	v, err := r.resolve(ctx, expr.Expression)
-	if err != nil {
-		return nil, err
+// resolveChainExpression resolves a selector, element access or call expression.
+// The returned bool reports that a `?.` in the chain met a null value, in which case
+// the rest of the chain is skipped and the whole chain evaluates to null.
+func (r *Runner) resolveChainExpression(ctx context.Context, expr Expression) (interface{}, bool, error) {
+	switch n := expr.(type) {
+	case *SelectorExpression:
+		v, skipped, err := r.resolveChainExpression(ctx, n.Expression)
+		if err != nil || skipped {
+			return nil, skipped, err
+		}
+		if n.Optional && IsNull(v) {
+			return nil, true, nil
+		}
+		res, err := r.resolveSelectorExpression(ctx, n, v)
+		return res, false, err
+	case *ElementAccessExpression:
+		v, skipped, err := r.resolveChainExpression(ctx, n.Expression)
+		if err != nil || skipped {
+			return nil, skipped, err
+		}
+		if n.Optional && IsNull(v) {
+			return nil, true, nil
+		}
+		res, err := r.resolveElementAccessExpression(ctx, n, v)
+		return res, false, err
+	case *CallExpression:
+		fun, skipped, err := r.resolveChainExpression(ctx, n.Expression)
+		if err != nil || skipped {
+			return nil, skipped, err
+		}
+		res, err := r.resolveCallExpression(ctx, n, fun)
+		return res, false, err
+	default:
+		v, err := r.resolve(ctx, expr)
+		return v, false, err
 	}
+}
+
+func (r *Runner) resolveSelectorExpression(ctx context.Context, expr *SelectorExpression, v interface{}) (interface{}, error) {
 	if IsNull(v) && expr.Assert {
 		return nil, fmt.Errorf("expr %s value is null, can't access attribute '%s'", astToString(expr.Expression), expr.Name.Value)
 	}
@@ -238,68 +346,160 @@ func getObjectValueFromKey(v interface{}, key string) (interface{}, error) {
 		// return rv.MapIndex(reflect.ValueOf(key)).Interface(), nil
 	case reflect.Struct:
 		field := rv.FieldByName(key)
+		if !field.IsValid() || !field.CanInterface() {
+			return nil, nil
+		}
 		return field.Interface(), nil
 	}
 	return nil, nil
 }
 
-func resolveSelecotrNames(expr Expression) ([]string, error) {
-	switch n := expr.(type) {
-	case *SelectorExpression:
-		arr, err := resolveSelecotrNames(n.Expression)
+func (r *Runner) resolveElementAccessExpression(ctx context.Context, expr *ElementAccessExpression, v interface{}) (interface{}, error) {
+	index, err := r.resolve(ctx, expr.ArgumentExpression)
+	if err != nil {
+		return nil, err
+	}
+	value, err := getObjectValueFromIndex(v, index)
+	if err != nil {
+		return nil, err
+	}
+	// 统一nil值
+	return formatNilValue(value), nil
+}
+
+func getObjectValueFromIndex(v interface{}, index interface{}) (interface{}, error) {
+	if IsNull(v) {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice:
+		i, err := convToIndex(index)
 		if err != nil {
 			return nil, err
 		}
-		return append(arr, n.Name.Value), nil
-	case *Identifier:
-		return []string{n.Value}, nil
-	default:
-		return nil, fmt.Errorf("selector name not support type %T", expr)
+		length := rv.Len()
+		// 负数下标从末尾开始计算
+		if i < 0 {
+			i += length
+		}
+		if i < 0 || i >= length {
+			return nil, fmt.Errorf("index %s out of range with length %d", convToString(index), length)
+		}
+		return rv.Index(i).Interface(), nil
+	case reflect.Map:
+		keyType := rv.Type().Key()
+		if keyType.Kind() == reflect.String {
+			return getObjectValueFromKey(v, convToString(index))
+		}
+		key, err := convTypeToTarget(index, keyType)
+		if err != nil {
+			return nil, err
+		}
+		mv := rv.MapIndex(reflect.ValueOf(key))
+		if !mv.IsValid() {
+			return nil, nil
+		}
+		return mv.Interface(), nil
+	case reflect.Struct:
+		return getObjectValueFromKey(v, convToString(index))
 	}
+	return nil, fmt.Errorf("can't access element of type %T", v)
 }
 
-func (r *Runner) resolveCallExpression(ctx context.Context, expr *CallExpression) (interface{}, error) {
-	fun, err := r.resolve(ctx, expr.Expression)
-	if err != nil {
-		return nil, err
+func convToIndex(v interface{}) (int, error) {
+	n := convToNumber(v)
+	if n.IsNaN(0) || !n.IsInt() {
+		return 0, fmt.Errorf("index %s is not an integer", convToString(v))
+	}
+	i, ok := n.Int64()
+	if !ok {
+		return 0, fmt.Errorf("index %s overflows int", convToString(v))
+	}
+	return int(i), nil
+}
+
+// resolveCallExpression calls fun with the arguments of expr, leading arguments are passed before them.
+func (r *Runner) resolveCallExpression(ctx context.Context, expr *CallExpression, fun interface{}, leading ...interface{}) (interface{}, error) {
+	// 函数名仅用于错误信息, 例如 `(x => x)(1)` 没有名字
+	name := "anonymous"
+	if names, err := resolveCallNames(expr.Expression); err == nil {
+		name = strings.Join(names, ".")
 	}
-	names, err := resolveCallNames(expr.Expression)
+	// 参数求值, (...) 数组展开
+	args, err := r.resolveElements(ctx, expr.Arguments)
 	if err != nil {
 		return nil, err
 	}
-	name := strings.Join(names, ".")
-	// 参数求值
-	var args []interface{}
-	if expr.Arguments != nil && expr.Arguments.Len() > 0 {
-		for i := 0; i < expr.Arguments.Len(); i++ {
-			av, err := r.resolve(ctx, expr.Arguments.At(i))
-			if err != nil {
-				return nil, err
-			}
-			args = append(args, av)
-		}
+	args = append(leading, args...)
+	if lambda, ok := fun.(*Lambda); ok {
+		return lambda.Call(ctx, args...)
 	}
-	funType := reflect.TypeOf(fun)
-	if funType.Kind() != reflect.Func {
+	signature := newFuncSignature(fun)
+	if signature == nil {
 		return nil, fmt.Errorf("expr %s value not is function", name)
 	}
-	hasVariadic := hasVariadicParameter(funType)
-	// (...)可用性检查
-	if expr.DotDotDotToken != nil && !hasVariadic {
-		return nil, fmt.Errorf("call function '%s' error: not have variadic parammeter", name)
+	return signature.call(ctx, name, args)
+}
+
+// funcSignature is the parameter analysis of a function value, it is made once and used for every
+// call of the function.
+type funcSignature struct {
+	fun        interface{}
+	value      reflect.Value
+	hasContext bool
+	variadic   bool
+	// Types of the parameters after the context, the element type for the variadic parameter
+	params []reflect.Type
+	convs  []func(v interface{}) (interface{}, error)
+}
+
+// newFuncSignature returns nil when fun is not a function.
+func newFuncSignature(fun interface{}) *funcSignature {
+	funType := reflect.TypeOf(fun)
+	if funType == nil || funType.Kind() != reflect.Func {
+		return nil
+	}
+	s := &funcSignature{
+		fun:        fun,
+		value:      reflect.ValueOf(fun),
+		hasContext: firstParamIsContext(funType),
+		variadic:   hasVariadicParameter(funType),
+	}
+	first := 0
+	if s.hasContext {
+		first = 1
+	}
+	for i := first; i < funType.NumIn(); i++ {
+		target := funType.In(i)
+		if s.variadic && i == funType.NumIn()-1 {
+			target = target.Elem()
+		}
+		s.params = append(s.params, target)
+		s.convs = append(s.convs, newArgConverter(target))
 	}
-	// 实参数量校验
-	paramCount := funType.NumIn()
-	// 最少要传递的参数个数
-	minArgsCount := paramCount
-	hasContextParam := 0
-	if firstParamIsContext(funType) {
-		hasContextParam = 1
+	return s
+}
+
+// newArgConverter converts arguments to target, an argument of the target type is passed as it is.
+func newArgConverter(target reflect.Type) func(v interface{}) (interface{}, error) {
+	if target.Kind() == reflect.Interface {
+		return func(v interface{}) (interface{}, error) {
+			return v, nil
+		}
 	}
-	if hasContextParam == 1 {
-		minArgsCount--
+	return func(v interface{}) (interface{}, error) {
+		if v != nil && reflect.TypeOf(v) == target {
+			return v, nil
+		}
+		return convTypeToTarget(v, target)
 	}
-	if !hasVariadic || expr.DotDotDotToken != nil {
+}
+
+func (s *funcSignature) call(ctx context.Context, name string, args []interface{}) (interface{}, error) {
+	// 实参数量校验, 最少要传递的参数个数
+	minArgsCount := len(s.params)
+	if !s.variadic {
 		if len(args) != minArgsCount {
 			return nil, fmt.Errorf("call function '%s' error: argument count except %d but got %d", name, minArgsCount, len(args))
 		}
@@ -308,49 +508,78 @@ func (r *Runner) resolveCallExpression(ctx context.Context, expr *CallExpression
 			return nil, fmt.Errorf("call function '%s' error: argument count except greater than or equal %d but got %d", name, minArgsCount-1, len(args))
 		}
 	}
-	// (...) 数组展开
-	if len(args) > 0 && expr.DotDotDotToken != nil {
-		expands, err := expandArrayArgument(args[len(args)-1])
-		if err != nil {
-			return nil, fmt.Errorf("call function '%s' error: %s", name, err.Error())
-		}
-		args = append(args[:len(args)-1], expands...)
-	}
 	// 参数转换
-	callArgs := []reflect.Value{}
-	if hasContextParam == 1 {
-		callArgs = append(callArgs, reflect.ValueOf(ctx))
-	}
-	for i := 0; i < len(args); i++ {
-		var targetType reflect.Type
-		if hasVariadic && i >= minArgsCount-1 {
-			targetType = funType.In(paramCount - 1)
-			targetType = targetType.Elem()
-		} else {
-			targetType = funType.In(i + hasContextParam)
+	convd := make([]interface{}, len(args))
+	for i, arg := range args {
+		conv := s.convs[len(s.convs)-1]
+		if i < len(s.convs) {
+			conv = s.convs[i]
 		}
-		convd, err := convTypeToTarget(args[i], targetType)
+		v, err := conv(arg)
 		if err != nil {
 			return nil, fmt.Errorf("call function '%s' conv arg#%d error: %s", name, i+1, err.Error())
 		}
-		if convd == nil {
+		convd[i] = v
+	}
+	result, err, ok := s.callDirect(convd)
+	if !ok {
+		result, err = s.callReflect(ctx, convd)
+	}
+	if err != nil {
+		return result, fmt.Errorf("call function '%s' error: %s", name, err.Error())
+	}
+	return result, nil
+}
+
+// callDirect calls the common function types without reflection, ok is false for other types.
+func (s *funcSignature) callDirect(args []interface{}) (result interface{}, err error, ok bool) {
+	switch f := s.fun.(type) {
+	case func(*decimal.Big) (*decimal.Big, error):
+		result, err = f(args[0].(*decimal.Big))
+	case func(...*decimal.Big) (*decimal.Big, error):
+		nums := make([]*decimal.Big, len(args))
+		for i, arg := range args {
+			nums[i] = arg.(*decimal.Big)
+		}
+		result, err = f(nums...)
+	case func(interface{}) (*decimal.Big, error):
+		result, err = f(args[0])
+	case func(string) (string, error):
+		result, err = f(args[0].(string))
+	case func(string, string) (bool, error):
+		result, err = f(args[0].(string), args[1].(string))
+	default:
+		return nil, nil, false
+	}
+	return result, err, true
+}
+
+func (s *funcSignature) callReflect(ctx context.Context, args []interface{}) (interface{}, error) {
+	callArgs := make([]reflect.Value, 0, len(args)+1)
+	if s.hasContext {
+		callArgs = append(callArgs, reflect.ValueOf(ctx))
+	}
+	for i, arg := range args {
+		if arg == nil {
 			// 根据参数类型创建对应类型的零值
-			nilValue := reflect.Zero(targetType)
-			callArgs = append(callArgs, reflect.ValueOf(nilValue))
+			target := s.params[len(s.params)-1]
+			if i < len(s.params) {
+				target = s.params[i]
+			}
+			callArgs = append(callArgs, reflect.Zero(target))
 		} else {
-			callArgs = append(callArgs, reflect.ValueOf(convd))
+			callArgs = append(callArgs, reflect.ValueOf(arg))
 		}
 	}
 	// 调用函数
-	results := reflect.ValueOf(fun).Call(callArgs)
+	results := s.value.Call(callArgs)
 	if len(results) != 2 {
-		return nil, fmt.Errorf("call function '%s' error: must return tow value but got %d", name, len(results))
+		return nil, fmt.Errorf("must return tow value but got %d", len(results))
 	}
 	if !results[1].IsNil() {
-		err = results[1].Interface().(error)
-		err = fmt.Errorf("call function '%s' error: %s", name, err.Error())
+		return results[0].Interface(), results[1].Interface().(error)
 	}
-	return results[0].Interface(), err
+	return results[0].Interface(), nil
 }
 
 func firstParamIsContext(funcType reflect.Type) bool {
@@ -537,6 +766,8 @@ func (r *Runner) resolvePlusUnaryExpression(v interface{}) (interface{}, error)
 	switch n := v.(type) {
 	case *decimal.Big:
 		return n, nil
+	case time.Duration:
+		return n, nil
 	case string:
 		r, err := strconv.Atoi(n)
 		if err != nil {
@@ -554,6 +785,8 @@ func (r *Runner) resolveMinusUnaryExpression(v interface{}) (interface{}, error)
 	switch n := v.(type) {
 	case *decimal.Big:
 		return newDecimalBig().Neg(n), nil
+	case time.Duration:
+		return -n, nil
 	case string:
 		r, err := strconv.Atoi(n)
 		if err != nil {
@@ -600,6 +833,22 @@ func (r *Runner) resolveBinaryExpression(ctx context.Context, expr *BinaryExpres
 	case SK_Equals:
 		return r.resolveEqualBinaryExpression(ctx, expr.Left, expr.Right)
 	}
+	if expr.Operator.Token.IsAssignmentOperator() {
+		return r.resolveCompoundAssignmentBinaryExpression(ctx, expr)
+	}
+	// Logical expression only resolve the right side when the left side can't decide the result
+	switch expr.Operator.Token {
+	case SK_AmpersandAmpersand: // &&
+		return r.resolveAmpersandAmpersandBinaryExpression(ctx, expr.Left, expr.Right)
+	case SK_BarBar: // ||
+		return r.resolveBarBarBinaryExpression(ctx, expr.Left, expr.Right)
+	case SK_QuestionQuestion: // ??
+		return r.resolveQuestionQuestionBinaryExpression(ctx, expr.Left, expr.Right)
+	}
+	// Pipeline passes the left side as the first argument of the right side
+	if expr.Operator.Token == SK_BarGreaterThan {
+		return r.resolveBarGreaterThanBinaryExpression(ctx, expr.Left, expr.Right)
+	}
 
 	v1, err := r.resolve(ctx, expr.Left)
 	if err != nil {
@@ -609,8 +858,11 @@ func (r *Runner) resolveBinaryExpression(ctx context.Context, expr *BinaryExpres
 	if err != nil {
 		return nil, err
 	}
+	return r.resolveBinaryOperation(expr, expr.Operator.Token, v1, v2)
+}
 
-	switch expr.Operator.Token {
+func (r *Runner) resolveBinaryOperation(expr *BinaryExpression, operator SyntaxKind, v1, v2 interface{}) (interface{}, error) {
+	switch operator {
 	case SK_LessThan: // <
 		return r.resolveLessThanBinaryExpressino(v1, v2)
 	case SK_GreaterThan: // >
@@ -629,6 +881,14 @@ func (r *Runner) resolveBinaryExpression(ctx context.Context, expr *BinaryExpres
 		return r.resolveSlashBinaryExpression(v1, v2)
 	case SK_Percent: // %
 		return r.resolvePercentBinaryExpression(v1, v2)
+	case SK_AsteriskAsterisk: // **
+		return r.resolveAsteriskAsteriskBinaryExpression(v1, v2)
+	case SK_LessThanLessThan: // <<
+		return r.resolveLessThanLessThanBinaryExpression(v1, v2)
+	case SK_GreaterThanGreaterThan: // >>
+		return r.resolveGreaterThanGreaterThanBinaryExpression(v1, v2)
+	case SK_GreaterThanGreaterThanGreaterThan: // >>>
+		return r.resolveGreaterThanGreaterThanGreaterThanBinaryExpression(v1, v2)
 	case SK_Ampersand: // &
 		return r.resolveAmpersandBinaryExpression(v1, v2)
 	case SK_Bar: // |
@@ -643,10 +903,14 @@ func (r *Runner) resolveBinaryExpression(ctx context.Context, expr *BinaryExpres
 		return r.resolveEqualsEqualsEqualsBinaryExpression(expr, v1, v2)
 	case SK_ExclamationEqualsEquals: // !==
 		return r.resolveNotEqualsEqualsBinaryExpression(expr, v1, v2)
-	case SK_AmpersandAmpersand: // &&
-		return r.resolveAmpersandAmpersandBinaryExpression(v1, v2)
-	case SK_BarBar: // ||
-		return r.resolveBarBarBinaryExpression(v1, v2)
+	case SK_InKeyword: // in
+		return r.valueIn(v1, v2)
+	case SK_NotKeyword: // not in
+		in, err := r.valueIn(v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		return !in, nil
 	case SK_Comma:
 		return r.resolveCommaBinaryExpression(v1, v2)
 	}
@@ -659,7 +923,16 @@ func (r *Runner) resolveLessThanBinaryExpressino(v1, v2 interface{}) (interface{
 		s1 := convToString(v1)
 		s2 := convToString(v2)
 		return s1 < s2, nil
+	case time.Time, time.Duration:
+		c, err := compareTime("<", v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		return c < 0, nil
 	default:
+		if err := timeOperandError("<", v1, v2); err != nil {
+			return nil, err
+		}
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
 		return n1.Cmp(n2) == -1, nil
@@ -672,7 +945,16 @@ func (r *Runner) resolveGreaterThanBinaryExpressino(v1, v2 interface{}) (interfa
 		s1 := convToString(v1)
 		s2 := convToString(v2)
 		return s1 > s2, nil
+	case time.Time, time.Duration:
+		c, err := compareTime(">", v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		return c > 0, nil
 	default:
+		if err := timeOperandError(">", v1, v2); err != nil {
+			return nil, err
+		}
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
 		return n1.Cmp(n2) == 1, nil
@@ -685,7 +967,16 @@ func (r *Runner) resolveLessThanEqualsBinaryExpressino(v1, v2 interface{}) (inte
 		s1 := convToString(v1)
 		s2 := convToString(v2)
 		return s1 <= s2, nil
+	case time.Time, time.Duration:
+		c, err := compareTime("<=", v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		return c <= 0, nil
 	default:
+		if err := timeOperandError("<=", v1, v2); err != nil {
+			return nil, err
+		}
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
 		return n1.Cmp(n2) <= 0, nil
@@ -698,7 +989,16 @@ func (r *Runner) resolveGreaterThanEqualsBinaryExpressino(v1, v2 interface{}) (i
 		s1 := convToString(v1)
 		s2 := convToString(v2)
 		return s1 >= s2, nil
+	case time.Time, time.Duration:
+		c, err := compareTime(">=", v1, v2)
+		if err != nil {
+			return nil, err
+		}
+		return c >= 0, nil
 	default:
+		if err := timeOperandError(">=", v1, v2); err != nil {
+			return nil, err
+		}
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
 		return n1.Cmp(n2) >= 0, nil
@@ -711,7 +1011,12 @@ func (r *Runner) resolvePlusBinaryExpression(v1, v2 interface{}) (interface{}, e
 		s1 := convToString(v1)
 		s2 := convToString(v2)
 		return s1 + s2, nil
+	case time.Time, time.Duration:
+		return resolveTimePlus(v1, v2)
 	default:
+		if isTimeType(v2) {
+			return resolveTimePlus(v1, v2)
+		}
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
 		return newDecimalBig().Add(n1, n2), nil
@@ -724,44 +1029,226 @@ func (r *Runner) resolveMinusBinaryExpressino(v1, v2 interface{}) (interface{},
 		s1 := convToString(v1)
 		s2 := convToString(v2)
 		return s1 + s2, nil
+	case time.Time, time.Duration:
+		return resolveTimeMinus(v1, v2)
 	default:
+		if isTimeType(v2) {
+			return resolveTimeMinus(v1, v2)
+		}
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
 		return newDecimalBig().Sub(n1, n2), nil
 	}
 }
 
+// resolveTimePlus evaluates `date + duration`, `duration + date` and `duration + duration`
+func resolveTimePlus(v1, v2 interface{}) (interface{}, error) {
+	switch n1 := v1.(type) {
+	case time.Time:
+		if n2, ok := v2.(time.Duration); ok {
+			return n1.Add(n2), nil
+		}
+	case time.Duration:
+		switch n2 := v2.(type) {
+		case time.Duration:
+			return n1 + n2, nil
+		case time.Time:
+			return n2.Add(n1), nil
+		}
+	}
+	return nil, fmt.Errorf("binary expression '+' not support type %T and %T", v1, v2)
+}
+
+// resolveTimeMinus evaluates `date - duration`, `date - date` and `duration - duration`
+func resolveTimeMinus(v1, v2 interface{}) (interface{}, error) {
+	switch n1 := v1.(type) {
+	case time.Time:
+		switch n2 := v2.(type) {
+		case time.Duration:
+			return n1.Add(-n2), nil
+		case time.Time:
+			return n1.Sub(n2), nil
+		}
+	case time.Duration:
+		if n2, ok := v2.(time.Duration); ok {
+			return n1 - n2, nil
+		}
+	}
+	return nil, fmt.Errorf("binary expression '-' not support type %T and %T", v1, v2)
+}
+
+// compareTime compares two dates or two durations, the result is -1, 0 or 1
+func compareTime(operator string, v1, v2 interface{}) (int, error) {
+	switch n1 := v1.(type) {
+	case time.Time:
+		if n2, ok := v2.(time.Time); ok {
+			switch {
+			case n1.Before(n2):
+				return -1, nil
+			case n1.After(n2):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case time.Duration:
+		if n2, ok := v2.(time.Duration); ok {
+			switch {
+			case n1 < n2:
+				return -1, nil
+			case n1 > n2:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("binary expression '%s' not support type %T and %T", operator, v1, v2)
+}
+
 func (r *Runner) resolveAsteriskBinaryExpressino(v1, v2 interface{}) (interface{}, error) {
+	if isTimeType(v1) || isTimeType(v2) {
+		return resolveTimeAsterisk(v1, v2)
+	}
 	n1 := convToNumber(v1)
 	n2 := convToNumber(v2)
 	return newDecimalBig().Mul(n1, n2), nil
 }
 
 func (r *Runner) resolveSlashBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if isTimeType(v1) || isTimeType(v2) {
+		return resolveTimeSlash(v1, v2)
+	}
 	n1 := convToNumber(v1)
 	n2 := convToNumber(v2)
 	return newDecimalBig().Quo(n1, n2), nil
 }
 
+// resolveTimeAsterisk evaluates `duration * number` and `number * duration`
+func resolveTimeAsterisk(v1, v2 interface{}) (interface{}, error) {
+	if n1, ok := v1.(time.Duration); ok && !isTimeType(v2) {
+		return scaleDuration("*", n1, v2)
+	}
+	if n2, ok := v2.(time.Duration); ok && !isTimeType(v1) {
+		return scaleDuration("*", n2, v1)
+	}
+	return nil, fmt.Errorf("binary expression '*' not support type %T and %T", v1, v2)
+}
+
+// resolveTimeSlash evaluates `duration / number`, and `duration / duration` as a number,
+// e.g. `(due - start) / 1d` is the number of days
+func resolveTimeSlash(v1, v2 interface{}) (interface{}, error) {
+	if n1, ok := v1.(time.Duration); ok {
+		switch n2 := v2.(type) {
+		case time.Duration:
+			if n2 == 0 {
+				return nil, errors.New("duration divided by zero")
+			}
+			return newDecimalBig().Quo(newDecimalBig().SetMantScale(int64(n1), 0), newDecimalBig().SetMantScale(int64(n2), 0)), nil
+		case time.Time:
+		default:
+			return scaleDuration("/", n1, v2)
+		}
+	}
+	return nil, fmt.Errorf("binary expression '/' not support type %T and %T", v1, v2)
+}
+
+// scaleDuration multiplies or divides d by a number, the result is rounded to nanoseconds
+func scaleDuration(operator string, d time.Duration, v interface{}) (interface{}, error) {
+	n := convToNumber(v)
+	if !n.IsFinite() {
+		return nil, fmt.Errorf("binary expression '%s' not support type %T and %T", operator, d, v)
+	}
+	result := newDecimalBig().SetMantScale(int64(d), 0)
+	if operator == "/" {
+		if n.Sign() == 0 {
+			return nil, errors.New("duration divided by zero")
+		}
+		result.Quo(result, n)
+	} else {
+		result.Mul(result, n)
+	}
+	nanos, ok := result.RoundToInt().Int64()
+	if !ok {
+		return nil, fmt.Errorf("duration %v %s %v is out of range", d, operator, v)
+	}
+	return time.Duration(nanos), nil
+}
+
+// timeOperandError rejects a date or duration in an operation that is not defined for them
+func timeOperandError(operator string, v1, v2 interface{}) error {
+	if isTimeType(v1) || isTimeType(v2) {
+		return fmt.Errorf("binary expression '%s' not support type %T and %T", operator, v1, v2)
+	}
+	return nil
+}
+
 func (r *Runner) resolvePercentBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError("%", v1, v2); err != nil {
+		return nil, err
+	}
 	n1 := convToNumber(v1)
 	n2 := convToNumber(v2)
 	return newDecimalBig().Rem(n1, n2), nil
 }
 
+func (r *Runner) resolveAsteriskAsteriskBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError("**", v1, v2); err != nil {
+		return nil, err
+	}
+	n1 := convToNumber(v1)
+	n2 := convToNumber(v2)
+	// 整数指数通过乘法计算, 结果是精确的
+	return decimal.Context128.Pow(newDecimalBig(), n1, n2), nil
+}
+
+func (r *Runner) resolveLessThanLessThanBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError("<<", v1, v2); err != nil {
+		return nil, err
+	}
+	i1, _ := convToNumber(v1).Int64()
+	i2, _ := convToNumber(v2).Int64()
+	return newDecimalBig().SetMantScale(i1<<(uint64(i2)&63), 0), nil
+}
+
+func (r *Runner) resolveGreaterThanGreaterThanBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError(">>", v1, v2); err != nil {
+		return nil, err
+	}
+	i1, _ := convToNumber(v1).Int64()
+	i2, _ := convToNumber(v2).Int64()
+	return newDecimalBig().SetMantScale(i1>>(uint64(i2)&63), 0), nil
+}
+
+func (r *Runner) resolveGreaterThanGreaterThanGreaterThanBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError(">>>", v1, v2); err != nil {
+		return nil, err
+	}
+	i1, _ := convToNumber(v1).Int64()
+	i2, _ := convToNumber(v2).Int64()
+	return newDecimalBig().SetUint64(uint64(i1) >> (uint64(i2) & 63)), nil
+}
+
 func (r *Runner) resolveAmpersandBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError("&", v1, v2); err != nil {
+		return nil, err
+	}
 	i1, _ := convToNumber(v1).Int64()
 	i2, _ := convToNumber(v2).Int64()
 	return newDecimalBig().SetFloat64(float64(i1 & i2)), nil
 }
 
 func (r *Runner) resolveBarBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError("|", v1, v2); err != nil {
+		return nil, err
+	}
 	i1, _ := convToNumber(v1).Int64()
 	i2, _ := convToNumber(v2).Int64()
 	return newDecimalBig().SetFloat64(float64(i1 | i2)), nil
 }
 
 func (r *Runner) resolveCaretBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+	if err := timeOperandError("^", v1, v2); err != nil {
+		return nil, err
+	}
 	i1, _ := convToNumber(v1).Int64()
 	i2, _ := convToNumber(v2).Int64()
 	return newDecimalBig().SetFloat64(float64(i1 ^ i2)), nil
@@ -776,6 +1263,10 @@ func (r *Runner) resolveNotEqualsBinaryExpression(expr *BinaryExpression, v1, v2
 }
 
 func (r *Runner) valueLikeEqualTo(v1, v2 interface{}) bool {
+	if isTimeType(v1) || isTimeType(v2) {
+		c, err := compareTime("==", v1, v2)
+		return err == nil && c == 0
+	}
 	if isDecimalBigType(v1) || isDecimalBigType(v2) {
 		n1 := convToNumber(v1)
 		n2 := convToNumber(v2)
@@ -794,6 +1285,42 @@ func (r *Runner) valueLikeEqualTo(v1, v2 interface{}) bool {
 	return IsNull(v1) && IsNull(v2) || v1 == v2
 }
 
+// valueIn reports whether v1 is an element of the array v2, a key of the map v2 or a substring of the string v2.
+func (r *Runner) valueIn(v1, v2 interface{}) (bool, error) {
+	if IsNull(v2) {
+		return false, nil
+	}
+	if s, ok := v2.(string); ok {
+		return strings.Contains(s, convToString(v1)), nil
+	}
+	rv := reflect.ValueOf(v2)
+	switch rv.Kind() {
+	case reflect.Map:
+		for _, key := range rv.MapKeys() {
+			k, err := formatInput(key.Interface())
+			if err != nil {
+				return false, err
+			}
+			if r.valueLikeEqualTo(v1, k) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			item, err := formatInput(rv.Index(i).Interface())
+			if err != nil {
+				return false, err
+			}
+			if r.valueLikeEqualTo(v1, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("binary expression 'in' not support type %T", v2)
+}
+
 func (r *Runner) resolveEqualsEqualsEqualsBinaryExpression(expr *BinaryExpression, v1, v2 interface{}) (interface{}, error) {
 	return r.valueEqualTo(v1, v2), nil
 }
@@ -820,22 +1347,63 @@ func (r *Runner) valueEqualTo(v1, v2 interface{}) bool {
 			s1 := v1.(string)
 			s2 := v2.(string)
 			return s1 == s2
+		case time.Time:
+			t1 := v1.(time.Time)
+			t2 := v2.(time.Time)
+			return t1.Equal(t2)
 		}
 	}
 	return false
 }
 
-func (r *Runner) resolveAmpersandAmpersandBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+// resolveBarGreaterThanBinaryExpression evaluates `x |> f(a)` as `f(x, a)` and `x |> f` as `f(x)`
+func (r *Runner) resolveBarGreaterThanBinaryExpression(ctx context.Context, left, right Expression) (interface{}, error) {
+	v, err := r.resolve(ctx, left)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := right.(*CallExpression)
+	if !ok {
+		call = &CallExpression{Expression: right}
+	}
+	fun, skipped, err := r.resolveChainExpression(ctx, call.Expression)
+	if err != nil || skipped {
+		return nil, err
+	}
+	return r.resolveCallExpression(ctx, call, fun, v)
+}
+
+func (r *Runner) resolveAmpersandAmpersandBinaryExpression(ctx context.Context, left, right Expression) (interface{}, error) {
+	v1, err := r.resolve(ctx, left)
+	if err != nil {
+		return nil, err
+	}
 	if r.toBool(v1) {
-		return v2, nil
+		return r.resolve(ctx, right)
 	} else {
 		return v1, nil
 	}
 }
 
-func (r *Runner) resolveBarBarBinaryExpression(v1, v2 interface{}) (interface{}, error) {
+func (r *Runner) resolveBarBarBinaryExpression(ctx context.Context, left, right Expression) (interface{}, error) {
+	v1, err := r.resolve(ctx, left)
+	if err != nil {
+		return nil, err
+	}
 	if !r.toBool(v1) {
-		return v2, nil
+		return r.resolve(ctx, right)
+	} else {
+		return v1, nil
+	}
+}
+
+func (r *Runner) resolveQuestionQuestionBinaryExpression(ctx context.Context, left, right Expression) (interface{}, error) {
+	v1, err := r.resolve(ctx, left)
+	if err != nil {
+		return nil, err
+	}
+	if IsNull(v1) {
+		return r.resolve(ctx, right)
 	} else {
 		return v1, nil
 	}
@@ -861,21 +1429,222 @@ func (r *Runner) resolveEqualBinaryExpression(ctx context.Context, left, right E
 	return v2, nil
 }
 
+// compoundAssignmentOperators maps `op=` to `op`
+var compoundAssignmentOperators = map[SyntaxKind]SyntaxKind{
+	SK_PlusEquals:                              SK_Plus,
+	SK_MinusEquals:                             SK_Minus,
+	SK_AsteriskEquals:                          SK_Asterisk,
+	SK_AsteriskAsteriskEquals:                  SK_AsteriskAsterisk,
+	SK_SlashEquals:                             SK_Slash,
+	SK_PercentEquals:                           SK_Percent,
+	SK_LessThanLessThanEquals:                  SK_LessThanLessThan,
+	SK_GreaterThanGreaterThanEquals:            SK_GreaterThanGreaterThan,
+	SK_GreaterThanGreaterThanGreaterThanEquals: SK_GreaterThanGreaterThanGreaterThan,
+	SK_AmpersandEquals:                         SK_Ampersand,
+	SK_BarEquals:                               SK_Bar,
+	SK_CaretEquals:                             SK_Caret,
+}
+
+func (r *Runner) resolveCompoundAssignmentBinaryExpression(ctx context.Context, expr *BinaryExpression) (interface{}, error) {
+	if !Is[*Identifier](expr.Left) {
+		return 0, errors.New("assignment expression left expression is not identifier")
+	}
+	identifierValue := expr.Left.(*Identifier).Value
+	if !strings.HasPrefix(identifierValue, "$") {
+		return 0, fmt.Errorf("assignment expression left identifier must start of '$' but %s", identifierValue)
+	}
+	v1, err := r.resolve(ctx, expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	v2, err := r.resolve(ctx, expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.resolveBinaryOperation(expr, compoundAssignmentOperators[expr.Operator.Token], v1, v2)
+	if err != nil {
+		return nil, err
+	}
+	r.SetThisValue(identifierValue, res)
+	return res, nil
+}
+
 func (r *Runner) resolveArrayLiteralExpression(ctx context.Context, expr *ArrayLiteralExpression) (interface{}, error) {
+	return r.resolveElements(ctx, expr.Elements)
+}
+
+// resolveElements resolves array literal elements or call arguments, expanding spread elements in place.
+func (r *Runner) resolveElements(ctx context.Context, elements *NodeList[Expression]) ([]interface{}, error) {
 	var list []interface{}
-	if expr.Elements != nil && expr.Elements.Len() > 0 {
-		for i := 0; i < expr.Elements.Len(); i++ {
-			item := expr.Elements.At(i)
-			v1, err := r.resolve(ctx, item)
+	for _, item := range elements.Array() {
+		if spread, ok := item.(*SpreadElement); ok {
+			v, err := r.resolve(ctx, spread.Expression)
+			if err != nil {
+				return nil, err
+			}
+			expands, err := expandArrayArgument(v)
 			if err != nil {
 				return nil, err
 			}
-			list = append(list, v1)
+			for _, e := range expands {
+				fv, err := formatInput(e)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, fv)
+			}
+			continue
 		}
+		v1, err := r.resolve(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v1)
 	}
 	return list, nil
 }
 
+func (r *Runner) resolveObjectLiteralExpression(ctx context.Context, expr *ObjectLiteralExpression) (interface{}, error) {
+	result := map[string]interface{}{}
+	for _, property := range expr.Properties.Array() {
+		switch n := property.(type) {
+		case *PropertyAssignment:
+			v, err := r.resolve(ctx, n.Initializer)
+			if err != nil {
+				return nil, err
+			}
+			result[propertyName(n.Name)] = v
+		case *ShorthandPropertyAssignment:
+			v, err := r.resolve(ctx, n.Name)
+			if err != nil {
+				return nil, err
+			}
+			result[n.Name.Value] = v
+		case *SpreadAssignment:
+			v, err := r.resolve(ctx, n.Expression)
+			if err != nil {
+				return nil, err
+			}
+			err = spreadObject(result, v)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func propertyName(name Expression) string {
+	switch n := name.(type) {
+	case *Identifier:
+		return n.Value
+	case *LiteralExpression:
+		return n.Value
+	}
+	return ""
+}
+
+// spreadObject copies the keys of a map or the fields of a struct into target.
+func spreadObject(target map[string]interface{}, v interface{}) error {
+	if IsNull(v) {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			target[convToString(iter.Key().Interface())] = iter.Value().Interface()
+		}
+		return nil
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if rt.Field(i).IsExported() {
+				target[rt.Field(i).Name] = rv.Field(i).Interface()
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("can't spread %T into object", v)
+}
+
+// resolveBlockExpression runs the statements in a new scope, `let` variables are not visible outside of it.
+func (r *Runner) resolveBlockExpression(ctx context.Context, expr *BlockExpression) (interface{}, error) {
+	saved := r.scope
+	r.scope = newScope(saved)
+	defer func() { r.scope = saved }()
+
+	var res interface{}
+	for _, statement := range expr.Statements.Array() {
+		switch n := statement.(type) {
+		case *VariableStatement:
+			v, err := r.resolve(ctx, n.Initializer)
+			if err != nil {
+				return nil, err
+			}
+			r.scope.values[n.Name.Value] = v
+			res = nil
+		case *ExpressionStatement:
+			v, err := r.resolve(ctx, n.Expression)
+			if err != nil {
+				return nil, err
+			}
+			res = v
+		default:
+			return nil, errors.New("unknown statement type")
+		}
+	}
+	return res, nil
+}
+
+// resolveMatchExpression returns the result of the first arm with a pattern equal to the value,
+// strict match compares with `===`, otherwise with `==`. The default arm `_` matches any value.
+func (r *Runner) resolveMatchExpression(ctx context.Context, expr *MatchExpression) (interface{}, error) {
+	v, err := r.resolve(ctx, expr.Expression)
+	if err != nil {
+		return nil, err
+	}
+	for _, arm := range expr.Arms.Array() {
+		matched := arm.Patterns.Len() == 0
+		for _, pattern := range arm.Patterns.Array() {
+			pv, err := r.resolve(ctx, pattern)
+			if err != nil {
+				return nil, err
+			}
+			if expr.Strict {
+				matched = r.valueEqualTo(v, pv)
+			} else {
+				matched = r.valueLikeEqualTo(v, pv)
+			}
+			if matched {
+				break
+			}
+		}
+		if matched {
+			return r.resolve(ctx, arm.Result)
+		}
+	}
+	return nil, nil
+}
+
+func (r *Runner) resolveTemplateExpression(ctx context.Context, expr *TemplateExpression) (interface{}, error) {
+	var result strings.Builder
+	result.WriteString(expr.Head)
+	for _, span := range expr.Spans.Array() {
+		v, err := r.resolve(ctx, span.Expression)
+		if err != nil {
+			return nil, err
+		}
+		// null 输出为空字符串
+		if !IsNull(v) {
+			result.WriteString(convToString(v))
+		}
+		result.WriteString(span.Literal)
+	}
+	return result.String(), nil
+}
+
 func (r *Runner) resolveParenthesizedExpression(ctx context.Context, expr *ParenthesizedExpression) (interface{}, error) {
 	v, err := r.resolve(ctx, expr.Expression)
 	if err != nil {
@@ -897,17 +1666,79 @@ func (r *Runner) resolveLiteralExpression(ctx context.Context, expr *LiteralExpr
 	case SK_CtxKeyword:
 		return ctx, nil
 	case SK_NumberLiteral:
-		r, ok := newDecimalBig().SetString(expr.Value)
+		r, ok := parseNumberLiteral(expr)
 		if !ok {
 			return nil, fmt.Errorf("%s not number literal", expr.Value)
 		}
+		// 15% => 0.15
+		if expr.Flags&TF_Percent != 0 {
+			r.Quo(r, newDecimalBig().SetUint64(100))
+		}
 		return r, nil
 	case SK_StringLiteral:
 		return r.resolveStringLiteralExpression(expr)
+	case SK_DateLiteral:
+		t, err := parseDateLiteral(expr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s not date literal", expr.Value)
+		}
+		return t, nil
+	case SK_DurationLiteral:
+		d, ok := parseDurationLiteral(expr.Value)
+		if !ok {
+			return nil, fmt.Errorf("%s not duration literal", expr.Value)
+		}
+		return d, nil
 	}
 	return nil, errors.New("unknown liternal expression")
 }
 
+// parseDurationLiteral converts `3d`, `1.5h` or `2h30m` to a time.Duration.
+func parseDurationLiteral(text string) (time.Duration, bool) {
+	var result time.Duration
+	for len(text) > 0 {
+		i := strings.IndexAny(text, "dhms")
+		if i <= 0 {
+			return 0, false
+		}
+		amount, ok := newDecimalBig().SetString(text[:i])
+		if !ok {
+			return 0, false
+		}
+		unit := text[i : i+1]
+		if strings.HasPrefix(text[i:], "ms") {
+			unit = "ms"
+		}
+		nanos, ok := amount.Mul(amount, newDecimalBig().SetMantScale(int64(durationUnits[unit]), 0)).Int64()
+		if !ok {
+			return 0, false
+		}
+		result += time.Duration(nanos)
+		text = text[i+len(unit):]
+	}
+	return result, true
+}
+
+// parseNumberLiteral converts the literal to an exact decimal, `0x1F`, `0b11` and `0o17` included.
+func parseNumberLiteral(expr *LiteralExpression) (*decimal.Big, bool) {
+	var base int
+	switch {
+	case expr.Flags&TF_HexSpecifier != 0:
+		base = 16
+	case expr.Flags&TF_BinarySpecifier != 0:
+		base = 2
+	case expr.Flags&TF_OctalSpecifier != 0:
+		base = 8
+	default:
+		return newDecimalBig().SetString(expr.Value)
+	}
+	i, ok := new(big.Int).SetString(expr.Value[2:], base)
+	if !ok {
+		return nil, false
+	}
+	return newDecimalBig().SetBigMantScale(i, 0), true
+}
+
 func (r *Runner) resolveStringLiteralExpression(expr *LiteralExpression) (interface{}, error) {
 	return expr.Value, nil
 }
@@ -935,20 +1766,30 @@ func (r *Runner) resolveConditionalExpression(ctx context.Context, expr *Conditi
 	}
 }
 
+func (r *Runner) resolveArrowFunction(ctx context.Context, expr *ArrowFunction) (interface{}, error) {
+	return &Lambda{runner: r, scope: r.scope, expr: expr}, nil
+}
+
 func (r *Runner) resolveTypeofExpression(ctx context.Context, expr *TypeOfExpression) (interface{}, error) {
 	value, err := r.resolve(ctx, expr.Expression)
 	if err != nil {
 		return nil, err
 	}
+	return typeOfValue(value), nil
+}
+
+func typeOfValue(value interface{}) string {
 	switch value.(type) {
 	case bool:
-		return "boolean", nil
+		return "boolean"
 	case string:
-		return "string", nil
+		return "string"
 	case *decimal.Big:
-		return "number", nil
+		return "number"
+	case time.Duration:
+		return "duration"
 	default:
-		return "object", nil
+		return "object"
 	}
 }
 
@@ -1178,7 +2019,32 @@ func funContains(s string, substr string) (bool, error) {
 	return strings.Contains(s, substr), nil
 }
 
-func funFind(s string, substr string) (int, error) {
+// find(s, substr) returns the index of substr in s,
+// find(arr, x => cond) returns the first item matching cond.
+func funFind(ctx context.Context, v interface{}, target interface{}) (interface{}, error) {
+	if fn, ok := target.(*Lambda); ok {
+		arr, err := convToArray(v)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range arr {
+			ok, err := fn.Call(ctx, item, i)
+			if err != nil {
+				return nil, err
+			}
+			if fn.runner.toBool(ok) {
+				return item, nil
+			}
+		}
+		return nil, nil
+	}
+	var s, substr string
+	if !IsNull(v) {
+		s = convToString(v)
+	}
+	if !IsNull(target) {
+		substr = convToString(target)
+	}
 	return strings.Index(s, substr), nil
 }
 
@@ -1207,8 +2073,15 @@ func funRight(v string, ld int) (string, error) {
 	return v[len(v)-l:], nil
 }
 
-func funLen(v string) (int, error) {
-	return len(v), nil
+func funLen(v interface{}) (int, error) {
+	if IsNull(v) {
+		return 0, nil
+	}
+	// 数组返回元素个数
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		return rv.Len(), nil
+	}
+	return len(convToString(v)), nil
 }
 
 func funLower(v string) (string, error) {
@@ -1267,6 +2140,157 @@ func funJoin(arr []string, join string) (string, error) {
 	return strings.Join(arr, join), nil
 }
 
+// FUNCTION ARRAY
+func funMap(ctx context.Context, v interface{}, fn *Lambda) ([]interface{}, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(arr))
+	for i, item := range arr {
+		mv, err := fn.Call(ctx, item, i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mv)
+	}
+	return result, nil
+}
+
+func funFilter(ctx context.Context, v interface{}, fn *Lambda) ([]interface{}, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for i, item := range arr {
+		ok, err := fn.Call(ctx, item, i)
+		if err != nil {
+			return nil, err
+		}
+		if fn.runner.toBool(ok) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func funReduce(ctx context.Context, v interface{}, fn *Lambda, initial interface{}) (interface{}, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return nil, err
+	}
+	acc := initial
+	for i, item := range arr {
+		acc, err = fn.Call(ctx, acc, item, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+func funSome(ctx context.Context, v interface{}, fn *Lambda) (bool, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return false, err
+	}
+	for i, item := range arr {
+		ok, err := fn.Call(ctx, item, i)
+		if err != nil {
+			return false, err
+		}
+		if fn.runner.toBool(ok) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func funEvery(ctx context.Context, v interface{}, fn *Lambda) (bool, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return false, err
+	}
+	for i, item := range arr {
+		ok, err := fn.Call(ctx, item, i)
+		if err != nil {
+			return false, err
+		}
+		if !fn.runner.toBool(ok) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func funSortBy(ctx context.Context, v interface{}, fn *Lambda) ([]interface{}, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]interface{}, len(arr))
+	for i, item := range arr {
+		keys[i], err = fn.Call(ctx, item, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	indexes := make([]int, len(arr))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return compareValues(keys[indexes[i]], keys[indexes[j]]) < 0
+	})
+	result := make([]interface{}, 0, len(arr))
+	for _, i := range indexes {
+		result = append(result, arr[i])
+	}
+	return result, nil
+}
+
+func funGroupBy(ctx context.Context, v interface{}, fn *Lambda) (map[string]interface{}, error) {
+	arr, err := convToArray(v)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	for i, item := range arr {
+		key, err := fn.Call(ctx, item, i)
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		if !IsNull(key) {
+			name = convToString(key)
+		}
+		group, _ := result[name].([]interface{})
+		result[name] = append(group, item)
+	}
+	return result, nil
+}
+
+// convToArray returns the items of an array or slice, null is an empty array.
+func convToArray(v interface{}) ([]interface{}, error) {
+	if IsNull(v) {
+		return nil, nil
+	}
+	return expandArrayArgument(v)
+}
+
+// compareValues orders numbers numerically and other values by their string form.
+func compareValues(v1, v2 interface{}) int {
+	if !isStringType(v1) || !isStringType(v2) {
+		n1 := convToNumber(v1)
+		n2 := convToNumber(v2)
+		if !n1.IsNaN(0) && !n2.IsNaN(0) {
+			return n1.Cmp(n2)
+		}
+	}
+	return strings.Compare(convToString(v1), convToString(v2))
+}
+
 // CONV
 func funToString(v interface{}) (string, error) {
 	return convToString(v), nil
@@ -2,10 +2,13 @@ package formula
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 )
@@ -39,6 +42,79 @@ func TestExpr(t *testing.T) {
 	}
 }
 
+func TestAsteriskAsteriskExpr(t *testing.T) {
+	simple := map[string]any{
+		"2 ** 10":       float64(1024),
+		"2 ** 3 ** 2":   float64(512),
+		"(2 ** 3) ** 2": float64(64),
+		"1.1 ** 2":      1.21,
+		"0.1 ** 3":      0.001,
+		"2 ** -2":       0.25,
+		"4 ** 0.5":      float64(2),
+		"(-2) ** 3":     float64(-8),
+		"-(2 ** 2)":     float64(-4),
+		"2 * 3 ** 2":    float64(18),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		v, err := NewRunner().Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+
+	for _, expr := range []string{"-2 ** 2", "typeof a ** 2"} {
+		_, err := ParseSourceCode([]byte(expr))
+		if err == nil {
+			t.Errorf("%s except error", expr)
+			return
+		}
+	}
+}
+
+func TestShiftExpr(t *testing.T) {
+	simple := map[string]any{
+		"1 << 4":       float64(16),
+		"256 >> 4":     float64(16),
+		"-16 >> 2":     float64(-4),
+		"16 >>> 2":     float64(4),
+		"-1 >>> 60":    float64(15),
+		"1 << 2 + 1":   float64(8),
+		"1 << 2 < 5":   true,
+		"(5 >> 1) & 1": float64(0),
+		"1 << 2 == 4":  true,
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		v, err := NewRunner().Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
 func TestCallExpr(t *testing.T) {
 	ctx := context.Background()
 	code, err := ParseSourceCode([]byte("toDay()"))
@@ -184,6 +260,12 @@ func TestGetObjectValueFromKey(t *testing.T) {
 		t.Error("except 10")
 		return
 	}
+	// 未导出的字段
+	v, _ = getObjectValueFromKey(struct{ Name, age string }{"a", "b"}, "age")
+	if v != nil {
+		t.Error("except nil")
+		return
+	}
 }
 
 func TestStringEqualsEqualsEqualsCmp(t *testing.T) {
@@ -626,3 +708,925 @@ func TestNullEqualsZero(t *testing.T) {
 		return
 	}
 }
+
+func TestElementAccessValue(t *testing.T) {
+	simple := map[string]any{
+		"items[0]":            float64(1),
+		"items[-1]":           float64(3),
+		"row['unit price']":   float64(9.5),
+		"matrix[1][0]":        float64(3),
+		"matrix[i][j]":        float64(4),
+		"persons[1].name":     "小红",
+		"persons[-2]['name']": "小明",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"items":  []int{1, 2, 3},
+			"row":    map[string]interface{}{"unit price": 9.5},
+			"matrix": [][]int{{1, 2}, {3, 4}},
+			"i":      1,
+			"j":      1,
+			"persons": []map[string]any{
+				{"name": "小明"},
+				{"name": "小红"},
+			},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestElementAccessOutOfRange(t *testing.T) {
+	ctx := context.Background()
+	for _, expr := range []string{"items[3]", "items[-4]", "items[0.5]"} {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"items": []int{1, 2, 3},
+		})
+		_, err = runner.Resolve(ctx, code.Expression)
+		if err == nil {
+			t.Errorf("%s except error", expr)
+			return
+		}
+	}
+}
+
+func TestOptionalChain(t *testing.T) {
+	simple := map[string]any{
+		"customer?.address?.city ?? '-'": "杭州",
+		"nobody?.address?.city ?? '-'":   "-",
+		"nobody?.address!.city":          nil,
+		"nobody?.tags[0]":                nil,
+		"nobody?.name.length()":          nil,
+		"customer?.tags?.[1]":            "vip",
+		"a?.5:1":                         float64(1),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"customer": map[string]any{
+				"address": map[string]any{"city": "杭州"},
+				"tags":    []string{"new", "vip"},
+			},
+			"nobody": nil,
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestQuestionQuestionExpression(t *testing.T) {
+	simple := map[string]any{
+		"null ?? 1":   float64(1),
+		"0 ?? 1":      float64(0),
+		"'' ?? 'a'":   "",
+		"a ?? b ?? 2": float64(2),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestShortCircuitExpression(t *testing.T) {
+	simple := map[string]any{
+		"x != null && lookup(x)":    false,
+		"x == null || lookup(x)":    true,
+		"'ok' ?? lookup(x)":         "ok",
+		"y != null && lookup(y)":    "found",
+		"false && ($1 = 1), $1":     nil,
+		"true || ($2 = 1), $2 ?? 0": float64(0),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"x": nil,
+			"y": "key",
+			"lookup": func(key string) (string, error) {
+				if key == "" {
+					return "", errors.New("key is required")
+				}
+				return "found", nil
+			},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestArrayFunctions(t *testing.T) {
+	simple := map[string]any{
+		"reduce(map(lines, x => x.price * x.qty), (acc, x) => acc + x, 0)": float64(37),
+		"len(join(map(filter(lines, x => x.qty > 1), x => x.name), ','))":  float64(3),
+		"find(lines, x => x.name == 'b').price":                            float64(5),
+		"find(lines, x => x.name == 'z')":                                  nil,
+		"find('hello', 'l')":                                               float64(2),
+		"some(lines, x => x.qty > 2)":                                      true,
+		"every(lines, x => x.qty > 2)":                                     false,
+		"join(map(sortBy(lines, x => -x.price), x => x.name), ',')":        "c,b,a",
+		"groupBy(lines, x => x.qty > 1 ? 'many' : 'one')['many'][1].name":  "c",
+		"map([1, 2, 3], (x, i) => x * i)[2]":                               float64(6),
+		"(x => y => x + y)(1)(2)":                                          float64(3),
+		"map(lines, max => max.qty)[0]":                                    float64(1),
+		"reduce([1, 2, 3], (acc, x) => acc + x * rate, 0)":                 float64(12),
+		"len(filter(lines, x => x.qty > 1)) + len([])":                     float64(2),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"rate": 2,
+			"lines": []map[string]any{
+				{"name": "a", "price": 3, "qty": 1},
+				{"name": "b", "price": 5, "qty": 2},
+				{"name": "c", "price": 8, "qty": 3},
+			},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestObjectLiteral(t *testing.T) {
+	simple := map[string]any{
+		"{ ok: false, message: 'error' }.message": "error",
+		"{ total: a + b }.total":                  float64(3),
+		"{ 'unit price': a }['unit price']":       float64(1),
+		"{ a }.a":                                 float64(1),
+		"{ ...base, x: 1 }.name":                  "base",
+		"{ ...base, name: 'override' }.name":      "override",
+		"{ name: 'first', ...base }.name":         "base",
+		"{ ...null, x: 1 }.x":                     float64(1),
+		"typeof { a: 1 }":                         "object",
+		"map([1, 2], x => { v: x * 2 })[1].v":     float64(4),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"a":    1,
+			"b":    2,
+			"base": map[string]interface{}{"name": "base"},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestTemplateValue(t *testing.T) {
+	simple := map[string]any{
+		"`hello`":                          "hello",
+		"`订单${no}金额超出${limit}`":            "订单A001金额超出1000.5",
+		"`${a + 1}-${b}`":                  "2-",
+		"`${`${no}`}`":                     "A001",
+		"`a\\`b\\${c}`":                    "a`b${c}",
+		"`${ { x: no }.x }`":               "A001",
+		"`total: ${a > 0 ? 'yes' : 'no'}`": "total: yes",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"no":    "A001",
+			"limit": 1000.5,
+			"a":     1,
+			"b":     nil,
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestUnterminatedTemplateExpression(t *testing.T) {
+	for _, expr := range []string{"`abc", "`${a`", "`${a"} {
+		_, err := ParseSourceCode([]byte(expr))
+		if err == nil {
+			t.Errorf("%s except error", expr)
+			return
+		}
+	}
+}
+
+func TestCommentValue(t *testing.T) {
+	simple := map[string]any{
+		"6 / 2 // 除法":              float64(3),
+		"6 /* 被除数 */ / /* 除数 */ 2": float64(3),
+		"// 注释\n1 + 2":             float64(3),
+		"[1, /* 2, */ 3][1]":       float64(3),
+		"`/* ${1} */`":             "/* 1 */",
+		"'// 不是注释'":                "// 不是注释",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		v, err := NewRunner().Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestInValue(t *testing.T) {
+	simple := map[string]any{
+		"status in ['paid', 'shipped']":     true,
+		"status not in ['paid', 'shipped']": false,
+		"'vip' in tags":                     true,
+		"'svip' in tags":                    false,
+		"1 in ['1', 2]":                     true,
+		"2.0 in nums":                       true,
+		"3 not in nums":                     true,
+		"'a' in attrs":                      true,
+		"'c' in attrs":                      false,
+		"'ell' in 'hello'":                  true,
+		"1 in null":                         false,
+		"1 + 1 in nums":                     true,
+		"!('x' in attrs)":                   true,
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"status": "paid",
+			"tags":   []string{"vip", "new"},
+			"nums":   []int{1, 2},
+			"attrs":  map[string]any{"a": 1, "b": nil},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	simple := map[string]any{
+		"$sum = 1, $sum += 2, $sum":           float64(3),
+		"$sum += 2, $sum":                     float64(2),
+		"$n = 3, $n *= 2":                     float64(6),
+		"$n = 3, $n -= 5, $n":                 float64(-2),
+		"$n = 1, $n /= 4, $n":                 0.25,
+		"$n = 7, $n %= 4":                     float64(3),
+		"$n = 2, $n **= 3":                    float64(8),
+		"$n = 1, $n <<= 3, $n >>= 1, $n":      float64(4),
+		"$n = -1, $n >>>= 60":                 float64(15),
+		"$n = 6, $n &= 3, $n |= 8, $n ^= 1":   float64(11),
+		"$s = 'a', $s += 'b', $s":             "ab",
+		"$total = 0, $total += price * qty":   float64(15),
+		"$a = $b = 2, $a += $b += 1, $a + $b": float64(8),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"price": 5,
+			"qty":   3,
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+
+	for _, expr := range []string{"sum += 1", "a.$b += 1", "1 += 2", "($a) *= 2", "$a + 1 -= 1"} {
+		_, err := ParseSourceCode([]byte(expr))
+		if err == nil {
+			t.Errorf("%s except error", expr)
+			return
+		}
+	}
+
+	// 普通赋值仍然在运行时报错
+	for _, expr := range []string{"a = 1", "1 = 2", "a.$b = 1"} {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		_, err = NewRunner().Resolve(ctx, code.Expression)
+		if err == nil {
+			t.Errorf("%s except error", expr)
+			return
+		}
+	}
+}
+
+func TestStatementsValue(t *testing.T) {
+	simple := map[string]any{
+		"let total = price * qty; total * 2":         float64(30),
+		"let a = 1\nlet b = a + 1\nb * 10":           float64(20),
+		"let qty = 10; price * qty":                  float64(50),
+		"let f = x => x * rate\nlet rate = 2\nf(3)":  float64(6),
+		"let x = 1; map([1, 2], x => x * 10)[1] + x": float64(21),
+		"1; 2; 3;":  float64(3),
+		"let a = 1": nil,
+		"$sum = 0; let add = x => $sum += x; add(1); add(2); $sum": float64(3),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"price": 5,
+			"qty":   3,
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+		// let variables must not leak into this
+		if _, ok := runner.this["total"]; ok {
+			t.Errorf("%s let variable leaks into this", expr)
+			return
+		}
+	}
+}
+
+func TestPipelineExpression(t *testing.T) {
+	simple := map[string]any{
+		"name |> replace('-', '') |> left(10) |> trim |> upper":  "ABCDEFGHI",
+		"upper(trim(left(replace(name, '-', ''), 10)))":          "ABCDEFGHI",
+		"lines |> map(x => x * 2) |> reduce((a, x) => a + x, 0)": float64(12),
+		"1 |> max(5, 3)":                             float64(5),
+		"3 |> (x => x * x)":                          float64(9),
+		"1 + 2 |> toString":                          "3",
+		"tags |> filter(x => x != 'b') |> join(',')": "a,c",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"name":  " a-b-c-d-e-f-g-h-i-j-k ",
+			"lines": []int{1, 2, 3},
+			"nums":  []int{9, 4},
+			"tags":  []string{"a", "b", "c"},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+
+	// argument count checks still apply to the piped call
+	code, err := ParseSourceCode([]byte("'a' |> upper('b')"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = NewRunner().Resolve(ctx, code.Expression)
+	if err == nil || !strings.Contains(err.Error(), "argument count") {
+		t.Errorf("except argument count error but got %v", err)
+		return
+	}
+}
+
+func TestSpreadElementValue(t *testing.T) {
+	simple := map[string]any{
+		"join([...a, 'x', ...b], ',')":      "1,2,x,3",
+		"join([...a, ...[], ...b], '')":     "123",
+		"max(...nums)":                      float64(9),
+		"max(nums...)":                      float64(9),
+		"max(1, ...nums, 20)":               float64(20),
+		"left(...['abc', 2])":               "ab",
+		"((x, y, z) => x + y + z)(...nums)": float64(14),
+		"1 |> max(...nums)":                 float64(9),
+		"[...nums][0] + 1":                  float64(10),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"a":    []string{"1", "2"},
+			"b":    []string{"3"},
+			"nums": []int{9, 4, 1},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+
+	// argument count is checked after expansion
+	for _, expr := range []string{"left(...['abc'])", "upper(...['a', 'b'])", "max(...1)"} {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		_, err = NewRunner().Resolve(ctx, code.Expression)
+		if err == nil {
+			t.Errorf("%s except error", expr)
+			return
+		}
+	}
+}
+
+func TestMatchValue(t *testing.T) {
+	simple := map[string]any{
+		"match (status) { 1 => 'new', 2, 3 => 'paid', _ => 'unknown' }":           "paid",
+		"match (status) { '3' => 'loose', _ => 'none' }":                          "loose",
+		"match strict (status) { '3' => 'loose', _ => 'none' }":                   "none",
+		"match strict (status) { 3 => 'strict', _ => 'none' }":                    "strict",
+		"match (status) { 1 => 'new' }":                                           nil,
+		"match (name) { 'a' => 1, 'b' => 2, _ => 0 } + 1":                         float64(3),
+		"match (true) { status > 5 => 'big', status > 2 => 'mid', _ => 'small' }": "mid",
+		"match (null) { null => 'null', _ => 'other' }":                           "null",
+		"match (status) { _ => 'first', 3 => 'second' }":                          "first",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"status": 3,
+			"name":   "b",
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestPercentLiteral(t *testing.T) {
+	percents := map[string]any{
+		"rate * 15%":    float64(30),
+		"15% * rate":    float64(30),
+		"12.5%":         0.125,
+		"(15%)":         0.15,
+		"max(15%, 0.1)": 0.15,
+		"15% == 0.15":   true,
+		"10 % 3":        float64(1),
+		"10%-3":         -2.9,
+		"15%%4":         0.15,
+		"1e2%":          float64(1),
+		"[5%][0]":       0.05,
+		"`${15%}`":      "0.15",
+	}
+	// 没有 WithPercentLiterals 时 % 都是取余
+	remainders := map[string]any{
+		"10%3":      float64(1),
+		"10%rate":   float64(10),
+		"10%-3":     float64(1),
+		"10%\n3":    float64(1),
+		"(10%\n3)":  float64(1),
+		"10%!a":     float64(0),
+		"10%/* */3": float64(1),
+	}
+
+	ctx := context.Background()
+	for _, item := range []struct {
+		simple map[string]any
+		opts   []ScanOption
+	}{{percents, []ScanOption{WithPercentLiterals()}}, {remainders, nil}} {
+		for expr, except := range item.simple {
+			code, err := ParseSourceCode([]byte(expr), item.opts...)
+			if err != nil {
+				t.Errorf("%s %v", expr, err)
+				return
+			}
+			runner := NewRunner()
+			runner.SetThis(map[string]interface{}{
+				"rate": 200,
+				"a":    0,
+			})
+			v, err := runner.Resolve(ctx, code.Expression)
+			if err != nil {
+				t.Errorf("%s %v", expr, err)
+				return
+			}
+			if v != except {
+				t.Errorf("%s except %v but got %v", expr, except, v)
+				return
+			}
+		}
+	}
+}
+
+func TestNumberLocale(t *testing.T) {
+	simple := []struct {
+		locale NumberLocale
+		expr   string
+		except any
+	}{
+		{NL_CommaGroup, "1,000.50", 1000.5},
+		{NL_CommaGroup, "1,234,567 + 1", float64(1234568)},
+		{NL_CommaGroup, "1,5 + 1", float64(6)},
+		{NL_CommaGroup, "max(1,23, 4)", float64(23)},
+		{NL_CommaGroup, "1,000%", float64(10)},
+		{NL_CommaDecimal, "1.000,50", 1000.5},
+		{NL_CommaDecimal, "2,5 * 2", float64(5)},
+		{NL_CommaDecimal, "1.234.567", float64(1234567)},
+		{NL_CommaDecimal, "max(1 , 2)", float64(2)},
+		{NL_Default, "max(1,000)", float64(1)},
+	}
+
+	ctx := context.Background()
+	for _, item := range simple {
+		code, err := ParseSourceCode([]byte(item.expr), WithNumberLocale(item.locale), WithPercentLiterals())
+		if err != nil {
+			t.Errorf("%s %v", item.expr, err)
+			return
+		}
+		v, err := NewRunner().Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", item.expr, err)
+			return
+		}
+		if v != item.except {
+			t.Errorf("%s except %v but got %v", item.expr, item.except, v)
+			return
+		}
+	}
+}
+
+func TestRadixLiteral(t *testing.T) {
+	simple := map[string]any{
+		"0x1F":               float64(31),
+		"0XfF":               float64(255),
+		"0xdead_beef":        float64(3735928559),
+		"0b1010":             float64(10),
+		"0B1111_0000":        float64(240),
+		"0o17":               float64(15),
+		"0O7_7_7":            float64(511),
+		"0x10 + 0b10 + 0o10": float64(26),
+		"0xFF % 0x10":        float64(15),
+		"-0x10":              float64(-16),
+		"0xFFFFFFFFFFFFFFFF == 18446744073709551615": true,
+		"1e3":               float64(1000),
+		"1E3":               float64(1000),
+		"1.5E-2":            0.015,
+		"2.5e+2":            float64(250),
+		".5e1":              float64(5),
+		"1_000e1_0 == 1e13": true,
+		"1_000_000.000_1":   1000000.0001,
+		"`${0x10}`":         "16",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		v, err := NewRunner().Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestFullWidthValue(t *testing.T) {
+	simple := map[string]any{
+		"max（1，2）":          float64(2),
+		"“abc” + ‘d’":       "abcd",
+		"“你好，世界”":           "你好，世界",
+		"‘say \"hi\"’":      "say \"hi\"",
+		"【1，2】[1]":          float64(2),
+		"1 《 2 ＆＆ 2 》 1":    true,
+		"1 ！＝ 2":            true,
+		"let $a ＝ 1；$a ＋ 1": float64(2),
+		"｛a：1｝.a":           float64(1),
+		"`${1 ＋ 1｝`":        "2",
+		"2 ＊ 3":             float64(6),
+		"7 ％ 2":             float64(1),
+		"1 ／＊ c ＊／ + 2":     float64(3),
+		"1 /* c *／ + 2":     float64(3),
+		"1 ／／ c\n+ 2":       float64(3),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr), WithFullWidthNormalization())
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		v, err := NewRunner().Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+}
+
+func TestQuotedIdentifier(t *testing.T) {
+	simple := map[string]any{
+		"@'Order Total' * 2":         float64(200),
+		"@\"2023-amount\" + @'a-b'":  float64(5),
+		"row.@'unit price'":          float64(9),
+		"@'match' + 1":               float64(8),
+		"@'1st' + @'1st'":            "yesyes",
+		"{ @'a-b' }.@'a-b'":          float64(3),
+		"@'Order Total' |> toString": "100",
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"Order Total": 100,
+			"2023-amount": 2,
+			"a-b":         3,
+			"match":       7,
+			"1st":         "yes",
+			"row":         map[string]interface{}{"unit price": 9},
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+
+	for expr, except := range map[string]string{
+		"@''":         "identifier expected",
+		"@Order":      "invalid character",
+		"@'Order + 1": "unexpected end of text",
+	} {
+		_, err := ParseSourceCode([]byte(expr))
+		if err == nil || !strings.Contains(err.Error(), except) {
+			t.Errorf("%s except error %q but got %v", expr, except, err)
+			return
+		}
+	}
+}
+
+func TestDateDurationValue(t *testing.T) {
+	simple := map[string]any{
+		"#2024-05-03# - #2024-05-01#":                           48 * time.Hour,
+		"#2024-05-01# + 3d == #2024-05-04#":                     true,
+		"3d + #2024-05-01# == #2024-05-04#":                     true,
+		"#2024-05-01T10:00:00+08:00# == #2024-05-01T02:00:00Z#": true,
+		"#2024-05-01# - 1d < #2024-05-01#":                      true,
+		"#2024-05-01 08:30# < #2024-05-01 09:00#":               true,
+		"#2024-05-01# === #2024-05-01T00:00:00#":                true,
+		"#2024-05-01# >= #2024-05-02#":                          false,
+		"2h30m":                                                 150 * time.Minute,
+		"2h30m - 30m":                                           2 * time.Hour,
+		"1ms + 1s":                                              1001 * time.Millisecond,
+		"1.5h == 90m":                                           true,
+		"1_000ms":                                               time.Second,
+		"3d > 71h":                                              true,
+		"1d in [24h, 1h]":                                       true,
+		"typeof 2h":                                             "duration",
+		"typeof (#2024-05-02# - #2024-05-01#)":                  "duration",
+		"year(#2024-05-01# + 1d)":                               float64(2024),
+		"dueDate > now()":                                       true,
+		"now() - createdAt >= 1d":                               true,
+		"match (true) { dueDate - now() < 1d => 'soon', _ => 'ok' }": "soon",
+		"-3d":                                -72 * time.Hour,
+		"-2h30m":                             -150 * time.Minute,
+		"+2h":                                2 * time.Hour,
+		"#2024-05-01# + -1d == #2024-04-30#": true,
+		"2 * 3d == 6d":                       true,
+		"3d * 2":                             144 * time.Hour,
+		"3d / 2":                             36 * time.Hour,
+		"1.5 * 1h":                           90 * time.Minute,
+		"3d / 12h":                           float64(6),
+	}
+
+	ctx := context.Background()
+	for expr, except := range simple {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		runner := NewRunner()
+		runner.SetThis(map[string]interface{}{
+			"dueDate":   time.Now().Add(time.Hour),
+			"createdAt": time.Now().Add(-48 * time.Hour),
+		})
+		v, err := runner.Resolve(ctx, code.Expression)
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		if v != except {
+			t.Errorf("%s except %v but got %v", expr, except, v)
+			return
+		}
+	}
+
+	for expr, except := range map[string]string{
+		"1 + #2024-05-01#":            "binary expression '+' not support type",
+		"#2024-05-01# + #2024-05-02#": "binary expression '+' not support type",
+		"1d - #2024-05-01#":           "binary expression '-' not support type",
+		"#2024-05-01# < 1d":           "binary expression '<' not support type",
+		"1 < #2024-05-01#":            "binary expression '<' not support type",
+		"3d % 2":                      "binary expression '%' not support type",
+		"3d ** 2":                     "binary expression '**' not support type",
+		"#2024-05-01# * 2":            "binary expression '*' not support type",
+		"2 / 3d":                      "binary expression '/' not support type",
+		"1d << 1":                     "binary expression '<<' not support type",
+		"1d & 1":                      "binary expression '&' not support type",
+		"3d / 0":                      "duration divided by zero",
+		"3d / 0d":                     "duration divided by zero",
+	} {
+		code, err := ParseSourceCode([]byte(expr))
+		if err != nil {
+			t.Errorf("%s %v", expr, err)
+			return
+		}
+		_, err = NewRunner().Resolve(ctx, code.Expression)
+		if err == nil || !strings.Contains(err.Error(), except) {
+			t.Errorf("%s except error %q but got %v", expr, except, err)
+			return
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -24,8 +25,54 @@ const (
 	TF_OctalSpecifier                // e.g. `0o777`
 	TF_ContainsSeparator             // e.g. `0b1100_0101`
 	TF_UnicodeEscape                 // e.g. `\u0000`
+	TF_Percent                       // e.g. `15%`
+	TF_DigitGroup                    // e.g. `1,000.50` with NL_CommaGroup
+	TF_QuotedIdentifier              // e.g. `@"Order Total"`
 )
 
+// NumberLocale decides how the scanner reads `,` and `.` in numeric literals.
+type NumberLocale int
+
+const (
+	NL_Default      NumberLocale = iota // `,` is always a comma token
+	NL_CommaGroup                       // `,` groups digits, e.g. `1,000.50`
+	NL_CommaDecimal                     // `,` is the decimal separator and `.` groups digits, e.g. `1.000,50`
+)
+
+type ScanOption func(s *Scanner)
+
+// WithNumberLocale makes the scanner read numeric literals such as `1,000.50` pasted from other software.
+func WithNumberLocale(locale NumberLocale) ScanOption {
+	return func(s *Scanner) {
+		s.numberLocale = locale
+	}
+}
+
+// WithPercentLiterals makes the scanner read a `%` right after a numeric literal as a percent sign,
+// e.g. `rate * 15%` is `rate * 0.15`. A remainder operator is written with a space, e.g. `10 % 3`.
+func WithPercentLiterals() ScanOption {
+	return func(s *Scanner) {
+		s.percentLiterals = true
+	}
+}
+
+// WithFullWidthNormalization makes the scanner read full-width punctuation and CJK quotes typed with
+// a Chinese input method as their ASCII equivalents, e.g. `max（1，2）` or `“abc”`. Every substitution
+// is reported as an Information diagnostic, positions are not changed.
+func WithFullWidthNormalization() ScanOption {
+	return func(s *Scanner) {
+		s.normalizeFullWidth = true
+	}
+}
+
+// WithTrivia makes the parser keep every token with its trivia in SourceCode.Tokens, so the
+// source can be emitted again or edited without losing spacing and comments, see SpliceNode.
+func WithTrivia() ScanOption {
+	return func(s *Scanner) {
+		s.keepTrivia = true
+	}
+}
+
 type Scanner struct {
 	text []byte
 	// Current position (end position of text of current token)
@@ -40,13 +87,23 @@ type Scanner struct {
 	token      SyntaxKind
 	tokenValue string
 	tokenFlags TokenFlags
+	// Comments scanned as trivia, in text order
+	comments []*CommentRange
+	// Options
+	numberLocale       NumberLocale
+	percentLiterals    bool
+	normalizeFullWidth bool
+	keepTrivia         bool
 	// Report error
 	onError ErrorHandler
 }
 
-func CreateScanner(text []byte, onError ErrorHandler) *Scanner {
+func CreateScanner(text []byte, onError ErrorHandler, opts ...ScanOption) *Scanner {
 	var scanner = new(Scanner)
 	scanner.onError = onError
+	for _, opt := range opts {
+		opt(scanner)
+	}
 	scanner.SetText(text)
 	return scanner
 }
@@ -61,8 +118,15 @@ func (s *Scanner) error(msg *DiagnosticMessage) {
 	}
 }
 
-func (s *Scanner) errorAtPos(msg *DiagnosticMessage, pos int, length int) {
+func (s *Scanner) errorAtPos(msg *DiagnosticMessage, pos int, length int, args ...interface{}) {
 	if s.onError != nil {
+		if len(args) > 0 {
+			msg = &DiagnosticMessage{
+				Code:     msg.Code,
+				Category: msg.Category,
+				Message:  formatStringFromArgs(msg.Message, args...),
+			}
+		}
 		s.onError(msg, pos, length)
 	}
 }
@@ -96,9 +160,9 @@ func (s *Scanner) scanNumberFragment() string {
 				s.errorAtPos(M_Numeric_separators_are_not_allowed_here, s.pos, 1)
 			}
 
-			start = s.pos
 			underlineStart = s.pos
 			s.pos += size
+			start = s.pos
 			continue
 		}
 
@@ -121,6 +185,22 @@ func (s *Scanner) scanNumberFragment() string {
 }
 
 func (s *Scanner) scanNumber() (SyntaxKind, string) {
+	if s.numberLocale != NL_Default {
+		s.tokenValue = s.scanLocaleNumber()
+	} else {
+		s.tokenValue = s.scanDefaultNumber()
+	}
+	s.scanPercentSuffix()
+	if s.tokenFlags&TF_Percent == 0 && s.scanDurationSuffix() {
+		return SK_DurationLiteral, s.tokenValue
+	}
+	// var kind = s.checkNumberSuffix()
+	s.checkForIdentifierStartAfterNumericLiteral()
+	// return kind, s.tokenValue
+	return SK_NumberLiteral, s.tokenValue
+}
+
+func (s *Scanner) scanDefaultNumber() string {
 	var start = s.pos
 	var mainFragment = s.scanNumberFragment()
 	var decimalFragment string
@@ -132,6 +212,29 @@ func (s *Scanner) scanNumber() (SyntaxKind, string) {
 	}
 
 	var end = s.pos
+	scientificFragment = s.scanExponentFragment()
+	if len(scientificFragment) > 0 {
+		end = s.pos
+	}
+
+	var result string
+	if s.tokenFlags&TF_ContainsSeparator != 0 {
+		result = mainFragment
+		if len(decimalFragment) > 0 {
+			result += "." + decimalFragment
+		}
+		if len(scientificFragment) > 0 {
+			result += scientificFragment
+		}
+	} else {
+		result = string(s.text[start:end]) // No need to use all the fragments; no _ removal needed
+	}
+	return result
+}
+
+// scanExponentFragment scans `e10`, `E+10` or `e-10`, returns "" when there is none.
+func (s *Scanner) scanExponentFragment() string {
+	var start = s.pos
 	if tar := s.peekCheck(0, func(ch rune) bool { return ch == 'e' || ch == 'E' }); tar >= 0 {
 		s.pos = tar
 		s.tokenFlags |= TF_Scientific
@@ -144,29 +247,130 @@ func (s *Scanner) scanNumber() (SyntaxKind, string) {
 		if len(finalFragment) == 0 {
 			s.error(M_Digit_expected)
 		} else {
-			scientificFragment = string(s.text[end:preNumericPart]) + finalFragment
-			end = s.pos
+			return string(s.text[start:preNumericPart]) + finalFragment
 		}
 	}
+	return ""
+}
 
-	var result string
-	if s.tokenFlags&TF_ContainsSeparator != 0 {
-		result = mainFragment
-		if len(decimalFragment) > 0 {
-			result += "." + decimalFragment
+// scanLocaleNumber scans a numeric literal with digit groups, e.g. `1,000.50`, or `1.000,50` with NL_CommaDecimal.
+// The result is always in the default form, e.g. `1000.50`.
+func (s *Scanner) scanLocaleNumber() string {
+	var start = s.pos
+	var groupSeparator, decimalSeparator byte = ',', '.'
+	if s.numberLocale == NL_CommaDecimal {
+		groupSeparator, decimalSeparator = '.', ','
+	}
+	// `f(1,000)` may mean two arguments
+	var inList = s.token == SK_OpenParen || s.token == SK_OpenBracket || s.token == SK_Comma
+	var ambiguous = false
+
+	var result = s.scanNumberFragment()
+	for s.isDigitGroup(groupSeparator) {
+		s.tokenFlags |= TF_DigitGroup
+		s.pos++
+		result += s.scanNumberFragment()
+		ambiguous = ambiguous || inList && groupSeparator == ','
+	}
+	if s.pos+1 < s.end && s.text[s.pos] == decimalSeparator && IsDigit(rune(s.text[s.pos+1])) {
+		s.tokenFlags |= TF_Decimal
+		s.pos++
+		result += "." + s.scanNumberFragment()
+		ambiguous = ambiguous || inList && decimalSeparator == ','
+	} else if s.numberLocale == NL_CommaDecimal && s.pos+1 < s.end && s.text[s.pos] == '.' && IsDigit(rune(s.text[s.pos+1])) {
+		// `1.5` is not a digit group, read it as a decimal
+		s.tokenFlags |= TF_Decimal
+		s.pos++
+		result += "." + s.scanNumberFragment()
+		ambiguous = true
+	}
+	result += s.scanExponentFragment()
+
+	if ambiguous {
+		s.errorAtPos(M_Numeric_literal_0_is_ambiguous_it_is_read_as_1, start, s.pos-start, string(s.text[start:s.pos]), result)
+	}
+	return result
+}
+
+// isDigitGroup reports whether the separator at the current position is followed by exactly 3 digits.
+func (s *Scanner) isDigitGroup(separator byte) bool {
+	var pos = s.pos
+	if pos+3 >= s.end || s.text[pos] != separator {
+		return false
+	}
+	for i := 1; i <= 3; i++ {
+		if !IsDigit(rune(s.text[pos+i])) {
+			return false
 		}
-		if len(scientificFragment) > 0 {
-			result += scientificFragment
+	}
+	return pos+4 >= s.end || !IsDigit(rune(s.text[pos+4]))
+}
+
+// scanPercentSuffix reads `15%` as a percentage WithPercentLiterals, the `%` must follow the digits
+// without a space.
+func (s *Scanner) scanPercentSuffix() {
+	if !s.percentLiterals || s.pos >= s.end || s.text[s.pos] != '%' {
+		return
+	}
+	s.tokenFlags |= TF_Percent
+	s.pos++
+	// `10%-3` was a remainder without percent literals
+	if s.pos < s.end && (s.text[s.pos] == '+' || s.text[s.pos] == '-') {
+		s.errorAtPos(M_Percent_sign_after_0_is_read_as_a_percentage, s.tokenPos, s.pos-s.tokenPos, string(s.text[s.tokenPos:s.pos-1]))
+	}
+}
+
+// durationUnits are the units of duration literals, e.g. `3d` or `2h30m`.
+var durationUnits = map[string]time.Duration{
+	"d":  24 * time.Hour,
+	"h":  time.Hour,
+	"m":  time.Minute,
+	"s":  time.Second,
+	"ms": time.Millisecond,
+}
+
+// scanDurationSuffix reads a unit after the number as a duration literal, e.g. `3d`, `1.5h` or `2h30m`,
+// the token value is the literal without separators, e.g. `1000ms` for `1_000ms`.
+func (s *Scanner) scanDurationSuffix() bool {
+	var unit = s.peekDurationUnit(s.pos)
+	if len(unit) == 0 {
+		return false
+	}
+	s.pos += len(unit)
+	var value = s.tokenValue + unit
+	// more components, e.g. `30m` of `2h30m`
+	for {
+		var pos = s.pos
+		for pos < s.end && IsDigit(rune(s.text[pos])) {
+			pos++
 		}
-	} else {
-		result = string(s.text[start:end]) // No need to use all the fragments; no _ removal needed
+		if pos == s.pos {
+			break
+		}
+		unit = s.peekDurationUnit(pos)
+		if len(unit) == 0 {
+			break
+		}
+		value += string(s.text[s.pos:pos]) + unit
+		s.pos = pos + len(unit)
 	}
+	s.tokenValue = value
+	return true
+}
 
-	s.tokenValue = result
-	// var kind = s.checkNumberSuffix()
-	s.checkForIdentifierStartAfterNumericLiteral()
-	// return kind, s.tokenValue
-	return SK_NumberLiteral, s.tokenValue
+// peekDurationUnit returns the duration unit at pos, or "" when there is none.
+func (s *Scanner) peekDurationUnit(pos int) string {
+	for _, unit := range []string{"ms", "d", "h", "m", "s"} {
+		var end = pos + len(unit)
+		if end > s.end || string(s.text[pos:end]) != unit {
+			continue
+		}
+		// `3days` is not a duration, `2h30m` is
+		if ch, _ := s.peek(end); end >= s.end || IsDigit(ch) || !s.isIdentifierPart(ch) {
+			return unit
+		}
+	}
+	return ""
 }
 
 func (s *Scanner) checkForIdentifierStartAfterNumericLiteral() {
@@ -226,7 +430,7 @@ func (s *Scanner) scanHexDigits(count int, scanAsManyAsPossible bool, canHaveSep
 		allowSeparator = canHaveSeparators
 		if ch >= 'A' && ch <= 'F' {
 			ch += 'a' - 'A'
-		} else if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z') {
+		} else if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f') {
 			break
 		}
 
@@ -241,9 +445,49 @@ func (s *Scanner) scanHexDigits(count int, scanAsManyAsPossible bool, canHaveSep
 	return string(valueChars)
 }
 
+// scanBinaryOrOctalDigits scans the digits of `0b1010` or `0o777`, `_` separators are allowed between digits.
+func (s *Scanner) scanBinaryOrOctalDigits(base rune) string {
+	var value bytes.Buffer
+	// For counting number of digits; Valid binaryIntegerLiteral must have at least one binary digit following B or b.
+	// Similarly valid octalIntegerLiteral must have at least one octal digit following o or O.
+	var separatorAllowed = false
+	var isPreviousTokenSeparator = false
+	for s.pos < s.end {
+		ch, size := utf8.DecodeRune(s.text[s.pos:])
+		if ch == '_' {
+			s.tokenFlags |= TF_ContainsSeparator
+			if separatorAllowed {
+				separatorAllowed = false
+				isPreviousTokenSeparator = true
+			} else if isPreviousTokenSeparator {
+				s.errorAtPos(M_Multiple_consecutive_numeric_separators_are_not_permitted, s.pos, 1)
+			} else {
+				s.errorAtPos(M_Numeric_separators_are_not_allowed_here, s.pos, 1)
+			}
+			s.pos += size
+			continue
+		}
+		separatorAllowed = true
+		if !IsDigit(ch) || ch-'0' >= base {
+			break
+		}
+		value.WriteRune(ch)
+		s.pos += size
+		isPreviousTokenSeparator = false
+	}
+	if isPreviousTokenSeparator {
+		// Literal ends with underscore - not allowed
+		s.errorAtPos(M_Numeric_separators_are_not_allowed_here, s.pos-1, 1)
+	}
+	return value.String()
+}
+
 func (s *Scanner) scanString() string {
 	ch, size := utf8.DecodeRune(s.text[s.pos:])
 	var quote = ch
+	if closing, ok := cjkQuotes[ch]; ok {
+		quote = closing
+	}
 	s.pos += size
 
 	var contents strings.Builder
@@ -277,6 +521,125 @@ func (s *Scanner) scanString() string {
 	return contents.String()
 }
 
+// scanDateLiteral scans `#2024-05-01#` or `#2024-05-01T10:00:00+08:00#`, the token value is the date text.
+func (s *Scanner) scanDateLiteral() string {
+	s.pos++
+	var start = s.pos
+	for s.pos < s.end && s.text[s.pos] != '#' && !IsLineBreak(rune(s.text[s.pos])) {
+		s.pos++
+	}
+	var value = string(s.text[start:s.pos])
+	if s.pos >= s.end || s.text[s.pos] != '#' {
+		s.error(M_Unterminated_date_literal)
+		return value
+	}
+	s.pos++
+	if _, err := parseDateLiteral(value); err != nil {
+		s.errorAtPos(M_Invalid_date_literal_0, s.tokenPos, s.pos-s.tokenPos, value)
+	}
+	return value
+}
+
+// dateLayouts are the accepted forms of date literals, a date without zone is in the local time zone.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+}
+
+func parseDateLiteral(text string) (time.Time, error) {
+	var err error
+	for _, layout := range dateLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Scans a template literal part starting at '`' or '}', up to the next '${' or the closing '`'.
+func (s *Scanner) scanTemplateAndSetTokenValue() SyntaxKind {
+	var startedWithBacktick = s.text[s.pos] == '`'
+	// '}' may be a full-width '｝'
+	_, size := utf8.DecodeRune(s.text[s.pos:])
+	s.pos += size
+
+	var contents strings.Builder
+	var start = s.pos
+	var resultingToken SyntaxKind
+	for {
+		if s.pos >= s.end {
+			contents.Write(s.text[start:s.pos])
+			s.error(M_Unterminated_template_literal)
+			if startedWithBacktick {
+				resultingToken = SK_NoSubstitutionTemplateLiteral
+			} else {
+				resultingToken = SK_TemplateTail
+			}
+			break
+		}
+
+		var ch = s.text[s.pos]
+		// '`'
+		if ch == '`' {
+			contents.Write(s.text[start:s.pos])
+			s.pos++
+			if startedWithBacktick {
+				resultingToken = SK_NoSubstitutionTemplateLiteral
+			} else {
+				resultingToken = SK_TemplateTail
+			}
+			break
+		}
+		// '${'
+		if ch == '$' && s.pos+1 < s.end && s.text[s.pos+1] == '{' {
+			contents.Write(s.text[start:s.pos])
+			s.pos += 2
+			if startedWithBacktick {
+				resultingToken = SK_TemplateHead
+			} else {
+				resultingToken = SK_TemplateMiddle
+			}
+			break
+		}
+		// Escape character
+		if ch == '\\' {
+			contents.Write(s.text[start:s.pos])
+			contents.WriteString(s.scanEscapeSequence())
+			start = s.pos
+			continue
+		}
+		// Speculated ECMAScript 6 Spec 11.8.6.1:
+		// <CR><LF> and <CR> LineTerminatorSequences are normalized to <LF> for Template Values
+		if ch == '\r' {
+			contents.Write(s.text[start:s.pos])
+			s.pos++
+			if s.pos < s.end && s.text[s.pos] == '\n' {
+				s.pos++
+			}
+			contents.WriteString("\n")
+			start = s.pos
+			continue
+		}
+		s.pos++
+	}
+
+	s.tokenValue = contents.String()
+	return resultingToken
+}
+
+// Rescans the current '}' token as the continuation of a template literal.
+func (s *Scanner) ReScanTemplateToken() SyntaxKind {
+	s.pos = s.tokenPos
+	s.token = s.scanTemplateAndSetTokenValue()
+	return s.token
+}
+
 func (s *Scanner) scanEscapeSequence() string {
 	s.pos++
 	if s.pos >= s.end {
@@ -443,6 +806,9 @@ func (s *Scanner) peekEqual(n int, ch rune) int {
 	var start = s.pos
 	for start < s.end {
 		cur, size := utf8.DecodeRune(s.text[start:])
+		if s.normalizeFullWidth {
+			cur, _ = fullWidthToASCII(cur)
+		}
 		start += size
 		n--
 		if n < 0 {
@@ -456,9 +822,17 @@ func (s *Scanner) peekEqual(n int, ch rune) int {
 }
 
 func (s *Scanner) Scan() SyntaxKind {
+	s.scan()
+	if s.normalizeFullWidth {
+		s.reportFullWidthCharacters()
+	}
+	return s.token
+}
+
+func (s *Scanner) scan() SyntaxKind {
 	s.startPos = s.pos
 	s.tokenFlags = TF_None
-	for {
+	for s.pos <= s.end {
 		s.tokenPos = s.pos
 		if s.pos >= s.end {
 			s.token = SK_EndOfFile
@@ -466,6 +840,9 @@ func (s *Scanner) Scan() SyntaxKind {
 		}
 
 		ch, size := utf8.DecodeRune(s.text[s.pos:])
+		if s.normalizeFullWidth {
+			ch, _ = fullWidthToASCII(ch)
+		}
 		switch ch {
 		case '\n', '\r':
 			s.tokenFlags |= TF_PrecedingLineBreak
@@ -474,6 +851,10 @@ func (s *Scanner) Scan() SyntaxKind {
 		case '\t', '\v', '\f', ' ':
 			s.pos += size
 			continue
+		case '#':
+			s.tokenValue = s.scanDateLiteral()
+			s.token = SK_DateLiteral
+			return s.token
 		case '!':
 			if tar := s.peekEqual(1, '='); tar >= 0 {
 				if tar := s.peekEqual(2, '='); tar >= 0 {
@@ -506,12 +887,20 @@ func (s *Scanner) Scan() SyntaxKind {
 			s.tokenValue = s.scanString()
 			s.token = SK_StringLiteral
 			return s.token
+		case '`':
+			s.token = s.scanTemplateAndSetTokenValue()
+			return s.token
 		case '&':
 			if tar := s.peekEqual(1, '&'); tar >= 0 {
 				s.pos = tar
 				s.token = SK_AmpersandAmpersand
 				return s.token
 			}
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_AmpersandEquals
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Ampersand
 			return s.token
@@ -524,14 +913,39 @@ func (s *Scanner) Scan() SyntaxKind {
 			s.token = SK_CloseParen
 			return s.token
 		case '%':
-			s.pos += 1
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_PercentEquals
+				return s.token
+			}
+			s.pos += size
 			s.token = SK_Percent
 			return s.token
 		case '*':
-			s.pos += 1
+			if tar := s.peekEqual(1, '*'); tar >= 0 {
+				if tar := s.peekEqual(2, '='); tar >= 0 {
+					s.pos = tar
+					s.token = SK_AsteriskAsteriskEquals
+					return s.token
+				}
+				s.pos = tar
+				s.token = SK_AsteriskAsterisk
+				return s.token
+			}
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_AsteriskEquals
+				return s.token
+			}
+			s.pos += size
 			s.token = SK_Asterisk
 			return s.token
 		case '+':
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_PlusEquals
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Plus
 			return s.token
@@ -540,6 +954,11 @@ func (s *Scanner) Scan() SyntaxKind {
 			s.token = SK_Comma
 			return s.token
 		case '-':
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_MinusEquals
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Minus
 			return s.token
@@ -559,24 +978,94 @@ func (s *Scanner) Scan() SyntaxKind {
 			s.token = SK_Dot
 			return s.token
 		case '/':
+			// Single-line comment
+			if tar := s.peekEqual(1, '/'); tar >= 0 {
+				s.reportFullWidthText(s.pos, tar)
+				s.pos = tar
+				for s.pos < s.end {
+					ch, size := utf8.DecodeRune(s.text[s.pos:])
+					if IsLineBreak(ch) {
+						break
+					}
+					s.pos += size
+				}
+				s.addComment(SK_SingleLineCommentTrivia, s.tokenPos, s.pos)
+				continue
+			}
+			// Multi-line comment
+			if tar := s.peekEqual(1, '*'); tar >= 0 {
+				s.reportFullWidthText(s.pos, tar)
+				s.pos = tar
+				var commentClosed = false
+				for s.pos < s.end {
+					ch, size := utf8.DecodeRune(s.text[s.pos:])
+					if s.normalizeFullWidth {
+						ch, _ = fullWidthToASCII(ch)
+					}
+					if tar := s.peekEqual(1, '/'); ch == '*' && tar >= 0 {
+						s.reportFullWidthText(s.pos, tar)
+						s.pos = tar
+						commentClosed = true
+						break
+					}
+					if IsLineBreak(ch) {
+						s.tokenFlags |= TF_PrecedingLineBreak
+					}
+					s.pos += size
+				}
+				if !commentClosed {
+					s.error(M_Asterisk_Slash_expected)
+				}
+				s.addComment(SK_MultiLineCommentTrivia, s.tokenPos, s.pos)
+				continue
+			}
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_SlashEquals
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Slash
 			return s.token
 		case '0':
-			if s.pos+2 < s.end {
-				if tar := s.peekCheck(1, func(ch rune) bool { return ch == 'x' || ch == 'X' }); tar >= 0 {
-					s.pos = tar
-					s.tokenValue = s.scanMinimumNumberOfHexDigits(1, false)
-					if len(s.tokenValue) == 0 {
-						s.error(M_Hexadecimal_digit_expected)
-						s.tokenValue = "0"
-					}
-					s.tokenValue = "0x" + s.tokenValue
-					s.tokenFlags |= TF_HexSpecifier
-					// s.token = s.checkNumberSuffix()
-					// return s.token
-					return SK_NumberLiteral
+			if tar := s.peekCheck(1, func(ch rune) bool { return ch == 'x' || ch == 'X' }); tar >= 0 {
+				s.pos = tar
+				s.tokenValue = s.scanMinimumNumberOfHexDigits(1, true)
+				if len(s.tokenValue) == 0 {
+					s.error(M_Hexadecimal_digit_expected)
+					s.tokenValue = "0"
 				}
+				s.tokenValue = "0x" + s.tokenValue
+				s.tokenFlags |= TF_HexSpecifier
+				s.checkForIdentifierStartAfterNumericLiteral()
+				s.token = SK_NumberLiteral
+				return s.token
+			}
+			if tar := s.peekCheck(1, func(ch rune) bool { return ch == 'b' || ch == 'B' }); tar >= 0 {
+				s.pos = tar
+				s.tokenValue = s.scanBinaryOrOctalDigits(2)
+				if len(s.tokenValue) == 0 {
+					s.error(M_Binary_digit_expected)
+					s.tokenValue = "0"
+				}
+				s.tokenValue = "0b" + s.tokenValue
+				s.tokenFlags |= TF_BinarySpecifier
+				s.checkForIdentifierStartAfterNumericLiteral()
+				s.token = SK_NumberLiteral
+				return s.token
+			}
+			if tar := s.peekCheck(1, func(ch rune) bool { return ch == 'o' || ch == 'O' }); tar >= 0 {
+				s.pos = tar
+				s.tokenValue = s.scanBinaryOrOctalDigits(8)
+				if len(s.tokenValue) == 0 {
+					s.error(M_Octal_digit_expected)
+					s.tokenValue = "0"
+				}
+				s.tokenValue = "0o" + s.tokenValue
+				s.tokenFlags |= TF_OctalSpecifier
+				s.checkForIdentifierStartAfterNumericLiteral()
+				s.token = SK_NumberLiteral
+				return s.token
 			}
 			// This fall-through is a deviation from the EcmaScript grammar. The grammar says that a leading zero
 			// can only be followed by an octal digit, a dot, or the end of the number literal. However, we are being
@@ -589,7 +1078,21 @@ func (s *Scanner) Scan() SyntaxKind {
 			s.pos += size
 			s.token = SK_Colon
 			return s.token
+		case ';':
+			s.pos += size
+			s.token = SK_Semicolon
+			return s.token
 		case '<':
+			if tar := s.peekEqual(1, '<'); tar >= 0 {
+				if tar := s.peekEqual(2, '='); tar >= 0 {
+					s.pos = tar
+					s.token = SK_LessThanLessThanEquals
+					return s.token
+				}
+				s.pos = tar
+				s.token = SK_LessThanLessThan
+				return s.token
+			}
 			if tar := s.peekEqual(1, '='); tar >= 0 {
 				s.pos = tar
 				s.token = SK_LessThanEquals
@@ -609,10 +1112,35 @@ func (s *Scanner) Scan() SyntaxKind {
 				s.token = SK_EqualsEquals
 				return s.token
 			}
+			if tar := s.peekEqual(1, '>'); tar >= 0 {
+				s.pos = tar
+				s.token = SK_EqualsGreaterThan
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Equals
 			return s.token
 		case '>':
+			if tar := s.peekEqual(1, '>'); tar >= 0 {
+				if tar := s.peekEqual(2, '>'); tar >= 0 {
+					if tar := s.peekEqual(3, '='); tar >= 0 {
+						s.pos = tar
+						s.token = SK_GreaterThanGreaterThanGreaterThanEquals
+						return s.token
+					}
+					s.pos = tar
+					s.token = SK_GreaterThanGreaterThanGreaterThan
+					return s.token
+				}
+				if tar := s.peekEqual(2, '='); tar >= 0 {
+					s.pos = tar
+					s.token = SK_GreaterThanGreaterThanEquals
+					return s.token
+				}
+				s.pos = tar
+				s.token = SK_GreaterThanGreaterThan
+				return s.token
+			}
 			if tar := s.peekEqual(1, '='); tar >= 0 {
 				s.pos = tar
 				s.token = SK_GreaterThanEquals
@@ -627,6 +1155,12 @@ func (s *Scanner) Scan() SyntaxKind {
 				s.token = SK_QuestionQuestion
 				return s.token
 			}
+			// `a?.5:1` is a conditional expression, not an optional chain
+			if tar := s.peekEqual(1, '.'); tar >= 0 && s.peekCheck(2, IsDigit) < 0 {
+				s.pos = tar
+				s.token = SK_QuestionDot
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Question
 			return s.token
@@ -639,6 +1173,11 @@ func (s *Scanner) Scan() SyntaxKind {
 			s.token = SK_CloseBracket
 			return s.token
 		case '^':
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_CaretEquals
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Caret
 			return s.token
@@ -648,9 +1187,43 @@ func (s *Scanner) Scan() SyntaxKind {
 				s.token = SK_BarBar
 				return s.token
 			}
+			if tar := s.peekEqual(1, '>'); tar >= 0 {
+				s.pos = tar
+				s.token = SK_BarGreaterThan
+				return s.token
+			}
+			if tar := s.peekEqual(1, '='); tar >= 0 {
+				s.pos = tar
+				s.token = SK_BarEquals
+				return s.token
+			}
 			s.pos += size
 			s.token = SK_Bar
 			return s.token
+		case '{':
+			s.pos += size
+			s.token = SK_OpenBrace
+			return s.token
+		case '}':
+			s.pos += size
+			s.token = SK_CloseBrace
+			return s.token
+		case '@':
+			// quoted identifier, e.g. `@"Order Total"` or `@'2023-amount'`
+			if s.peekEqual(1, '"') >= 0 || s.peekEqual(1, '\'') >= 0 {
+				s.pos += size
+				s.tokenValue = s.scanString()
+				s.tokenFlags |= TF_QuotedIdentifier
+				if len(s.tokenValue) == 0 {
+					s.errorAtPos(M_Identifier_expected, s.tokenPos, s.pos-s.tokenPos)
+				}
+				s.token = SK_Identifier
+				return s.token
+			}
+			s.error(M_Invalid_character)
+			s.pos += size
+			s.token = SK_Unknown
+			return s.token
 		case '~':
 			s.pos += size
 			s.token = SK_Tilde
@@ -684,12 +1257,62 @@ func (s *Scanner) Scan() SyntaxKind {
 	return SK_Unknown
 }
 
+// reportFullWidthCharacters reports the full-width characters read as ASCII in the current token.
+func (s *Scanner) reportFullWidthCharacters() {
+	var pos = s.tokenPos
+	switch s.token {
+	case SK_NoSubstitutionTemplateLiteral, SK_TemplateHead:
+		return
+	case SK_StringLiteral:
+		// only the quotes, the contents are kept as typed
+		first, size := utf8.DecodeRune(s.text[pos:])
+		s.reportFullWidthCharacter(first, pos, size)
+		last, lastSize := utf8.DecodeLastRune(s.text[pos+size : s.pos])
+		if last == first || last == cjkQuotes[first] {
+			s.reportFullWidthCharacter(last, s.pos-lastSize, lastSize)
+		}
+		return
+	}
+	s.reportFullWidthText(pos, s.pos)
+}
+
+// reportFullWidthText reports the full-width characters between pos and end, e.g. the delimiters
+// of a comment, the text of a comment is not reported.
+func (s *Scanner) reportFullWidthText(pos int, end int) {
+	if !s.normalizeFullWidth {
+		return
+	}
+	for pos < end {
+		ch, size := utf8.DecodeRune(s.text[pos:])
+		s.reportFullWidthCharacter(ch, pos, size)
+		pos += size
+	}
+}
+
+func (s *Scanner) reportFullWidthCharacter(ch rune, pos int, size int) {
+	if normalized, ok := fullWidthToASCII(ch); ok {
+		s.errorAtPos(M_Character_0_is_read_as_1, pos, size, string(ch), string(normalized))
+	}
+}
+
+func (s *Scanner) addComment(kind SyntaxKind, pos int, end int) {
+	// Lookahead rescans the same text, only keep comments not seen before.
+	if n := len(s.comments); n > 0 && pos <= s.comments[n-1].Pos() {
+		return
+	}
+	var comment = &CommentRange{Kind: kind, Text: string(s.text[pos:end])}
+	comment.SetPos(pos)
+	comment.SetEnd(end)
+	s.comments = append(s.comments, comment)
+}
+
 func (s *Scanner) SetText(newText []byte) {
 	// full start and length
 	s.pos = 0
 	s.end = len(newText)
 
 	s.text = newText
+	s.comments = nil
 	s.SetTextPos(s.pos)
 }
 
@@ -728,6 +1351,15 @@ func (s *Scanner) GetTokenValue() string {
 	return s.tokenValue
 }
 
+// GetComments returns the comments scanned so far.
+func (s *Scanner) GetComments() []*CommentRange {
+	return s.comments
+}
+
+func (s *Scanner) GetTokenFlags() TokenFlags {
+	return s.tokenFlags
+}
+
 func (s *Scanner) HasPrecedingLineBreak() bool {
 	return s.tokenFlags&TF_PrecedingLineBreak != 0
 }
@@ -862,6 +1494,40 @@ func PositionToLineAndCharacter(text []byte, pos int) Position {
 	return GetLineAndCharacterOfPosition(text, lineStarts, pos)
 }
 
+// cjkQuotes maps the opening CJK quotes to their closing quotes.
+var cjkQuotes = map[rune]rune{
+	'“': '”',
+	'‘': '’',
+}
+
+// fullWidthToASCII returns the ASCII punctuation a Chinese input method produces ch for, e.g. `（` for `(`.
+// Full-width letters and digits, `＄`, `＿`, `｀` and `．` are not read as ASCII, they are part of
+// identifiers, numbers or templates.
+func fullWidthToASCII(ch rune) (rune, bool) {
+	switch ch {
+	case '“', '”':
+		return '"', true
+	case '‘', '’':
+		return '\'', true
+	case '【':
+		return '[', true
+	case '】':
+		return ']', true
+	case '《':
+		return '<', true
+	case '》':
+		return '>', true
+	}
+	if ch < 0xFF01 || ch > 0xFF5E {
+		return ch, false
+	}
+	var ascii = ch - 0xFEE0
+	if IsDigit(ascii) || ascii >= 'a' && ascii <= 'z' || ascii >= 'A' && ascii <= 'Z' || strings.ContainsRune("$_`.", ascii) {
+		return ch, false
+	}
+	return ascii, true
+}
+
 func IsWhiteSpace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\v' || ch == '\f' ||
 		ch == Uni_NonBreakingSpace || ch == Uni_Ogham ||
@@ -958,6 +1624,42 @@ func GetFilePositionFromLineAndCharacter(file *SourceCode, line int, character i
 	return GetPositionFromLineAndCharacter(file.Text, file.LineStarts, line, character)
 }
 
+// SkipTrivia returns the position of the first token text at or after pos, skipping whitespace and comments.
+func SkipTrivia(text []byte, pos int) int {
+	for pos < len(text) {
+		ch, size := utf8.DecodeRune(text[pos:])
+		if ch == '/' && pos+1 < len(text) {
+			switch text[pos+1] {
+			case '/':
+				pos += 2
+				for pos < len(text) {
+					ch, size := utf8.DecodeRune(text[pos:])
+					if IsLineBreak(ch) {
+						break
+					}
+					pos += size
+				}
+				continue
+			case '*':
+				pos += 2
+				for pos < len(text) {
+					if text[pos] == '*' && pos+1 < len(text) && text[pos+1] == '/' {
+						pos += 2
+						break
+					}
+					pos++
+				}
+				continue
+			}
+		}
+		if !IsWhiteSpace(ch) && !IsLineBreak(ch) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
 func TokenIsIdentifierOrKeyword(tok SyntaxKind) bool {
 	return tok >= SK_Identifier
 }
@@ -1,5 +1,436 @@
 package formula
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Precedences of the expressions that are not binary expressions, higher binds tighter.
+// The binary operators are between precedenceAssignment and precedenceUnary, see getBinaryOperatorPrecedence.
+const (
+	precedenceComma      = -1
+	precedenceAssignment = 0  // assignment, conditional and arrow function
+	precedenceUnary      = 13 // prefix unary and typeof
+	precedenceMember     = 14 // call, selector and element access
+	precedencePrimary    = 15
+)
+
+// Format parses a formula and prints it in the canonical style, e.g. `a+(b*c)` becomes `a + b * c`.
+// Redundant parentheses are removed, strings are single quoted and statements are separated by `; `.
+// A formula with comments is not formatted, the comments would be lost. The options are the ones to
+// parse the formula with, e.g. WithPercentLiterals.
+func Format(source []byte, opts ...ScanOption) ([]byte, error) {
+	code, err := ParseSourceCode(source, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(code.Comments) > 0 {
+		return nil, fmt.Errorf("can't format a formula with comments")
+	}
+	return []byte(astToString(code)), nil
+}
+
+// astToString prints a node in the canonical style, parentheses are only added where the
+// precedence requires them.
 func astToString(node Node) string {
-	return "[not impl]"
+	var p printer
+	p.print(node)
+	return p.buf.String()
+}
+
+type printer struct {
+	buf strings.Builder
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+}
+
+func (p *printer) print(node Node) {
+	switch n := node.(type) {
+	case *SourceCode:
+		if n.Statements != nil {
+			p.printStatements(n.Statements)
+		} else if n.Expression != nil {
+			p.print(n.Expression)
+		}
+	case *BlockExpression:
+		p.printStatements(n.Statements)
+	case *VariableStatement:
+		p.write("let ")
+		p.printName(n.Name.Value, false)
+		p.write(" = ")
+		p.printOperand(n.Initializer, precedenceAssignment)
+	case *ExpressionStatement:
+		p.print(n.Expression)
+	case *TokenNode:
+		p.write(n.Token.ToString())
+	case *Identifier:
+		p.printName(n.Value, false)
+	case *LiteralExpression:
+		p.printLiteral(n)
+	case *ParenthesizedExpression:
+		// parentheses are added by the precedence of the parent
+		p.print(n.Expression)
+	case *PrefixUnaryExpression:
+		p.write(n.Operator.Token.ToString())
+		// `!(!a)` is not `!!a`
+		if operand, ok := skipParentheses(n.Operand).(*PrefixUnaryExpression); ok && n.Operator.Token == SK_Exclamation &&
+			(operand.Operator.Token == SK_Exclamation || operand.Operator.Token == SK_ExclamationExclamation) {
+			p.write(" ")
+		}
+		p.printOperand(n.Operand, precedenceUnary)
+	case *TypeOfExpression:
+		p.write("typeof ")
+		p.printOperand(n.Expression, precedenceUnary)
+	case *BinaryExpression:
+		p.printBinaryExpression(n)
+	case *ConditionalExpression:
+		p.printOperand(n.Condition, precedenceAssignment+1)
+		p.write(" ? ")
+		p.printOperand(n.WhenTrue, precedenceAssignment)
+		p.write(" : ")
+		p.printOperand(n.WhenFalse, precedenceAssignment)
+	case *ArrowFunction:
+		if n.Parameters.Len() == 1 {
+			p.printName(n.Parameters.At(0).Value, false)
+		} else {
+			p.write("(")
+			for i, param := range n.Parameters.Array() {
+				if i > 0 {
+					p.write(", ")
+				}
+				p.printName(param.Value, false)
+			}
+			p.write(")")
+		}
+		p.write(" => ")
+		p.printOperand(n.Body, precedenceAssignment)
+	case *ArrayLiteralExpression:
+		p.write("[")
+		p.printList(n.Elements.Array())
+		p.write("]")
+	case *ObjectLiteralExpression:
+		if n.Properties.Len() == 0 {
+			p.write("{}")
+			break
+		}
+		p.write("{ ")
+		for i, property := range n.Properties.Array() {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.print(property)
+		}
+		p.write(" }")
+	case *PropertyAssignment:
+		if name, ok := n.Name.(*Identifier); ok {
+			p.printName(name.Value, true)
+		} else {
+			p.print(n.Name)
+		}
+		p.write(": ")
+		p.printOperand(n.Initializer, precedenceAssignment)
+	case *ShorthandPropertyAssignment:
+		p.printName(n.Name.Value, false)
+	case *SpreadAssignment:
+		p.write("...")
+		p.printOperand(n.Expression, precedenceAssignment)
+	case *SpreadElement:
+		p.write("...")
+		p.printOperand(n.Expression, precedenceAssignment)
+	case *TemplateExpression:
+		p.write("`" + escapeTemplateText(n.Head))
+		for _, span := range n.Spans.Array() {
+			p.print(span)
+		}
+		p.write("`")
+	case *TemplateSpan:
+		p.write("${")
+		p.print(n.Expression)
+		p.write("}" + escapeTemplateText(n.Literal))
+	case *SelectorExpression:
+		// `1.x` is read as the number `1.`
+		if literal, ok := skipParentheses(n.Expression).(*LiteralExpression); ok && literal.Token == SK_NumberLiteral {
+			p.write("(")
+			p.print(literal)
+			p.write(")")
+		} else {
+			p.printMemberOperand(n.Expression)
+		}
+		switch {
+		case n.Optional:
+			p.write("?.")
+		case n.Assert:
+			p.write("!.")
+		default:
+			p.write(".")
+		}
+		p.printName(n.Name.Value, true)
+	case *ElementAccessExpression:
+		p.printMemberOperand(n.Expression)
+		if n.Optional {
+			p.write("?.")
+		}
+		p.write("[")
+		p.print(n.ArgumentExpression)
+		p.write("]")
+	case *CallExpression:
+		p.printMemberOperand(n.Expression)
+		p.write("(")
+		p.printList(n.Arguments.Array())
+		p.write(")")
+	case *MatchExpression:
+		p.write("match ")
+		if n.Strict {
+			p.write("strict ")
+		}
+		p.write("(")
+		p.print(n.Expression)
+		p.write(") {")
+		for i, arm := range n.Arms.Array() {
+			if i > 0 {
+				p.write(",")
+			}
+			p.write(" ")
+			p.print(arm)
+		}
+		if n.Arms.Len() > 0 {
+			p.write(" ")
+		}
+		p.write("}")
+	case *MatchArm:
+		if n.Patterns.Len() == 0 {
+			p.write("_")
+		}
+		for i, pattern := range n.Patterns.Array() {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.printOperand(pattern, precedenceAssignment+1)
+		}
+		p.write(" => ")
+		p.printOperand(n.Result, precedenceAssignment)
+	default:
+		p.write(fmt.Sprintf("[%T]", node))
+	}
+}
+
+func (p *printer) printStatements(statements *NodeList[Statement]) {
+	for i, statement := range statements.Array() {
+		if i > 0 {
+			p.write("; ")
+		}
+		p.print(statement)
+	}
+}
+
+// printList prints the elements of an array literal or an argument list
+func (p *printer) printList(elements []Expression) {
+	for i, element := range elements {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.printOperand(element, precedenceAssignment)
+	}
+}
+
+func (p *printer) printBinaryExpression(n *BinaryExpression) {
+	var operator = n.Operator.Token
+	switch {
+	case operator == SK_Comma:
+		p.printOperand(n.Left, precedenceComma)
+		p.write(", ")
+		p.printOperand(n.Right, precedenceAssignment)
+	case operator.IsAssignmentOperator():
+		p.printOperand(n.Left, precedenceMember)
+		p.write(" " + operator.ToString() + " ")
+		p.printOperand(n.Right, precedenceAssignment)
+	case operator == SK_AsteriskAsterisk:
+		// right associative, and `-2 ** 2` is not allowed
+		var precedence = getBinaryOperatorPrecedence(operator)
+		p.printOperand(n.Left, precedenceMember)
+		p.write(" ** ")
+		p.printOperand(n.Right, precedence)
+	default:
+		var precedence = getBinaryOperatorPrecedence(operator)
+		p.printOperand(n.Left, precedence)
+		if operator == SK_NotKeyword {
+			p.write(" not in ")
+		} else {
+			p.write(" " + operator.ToString() + " ")
+		}
+		p.printOperand(n.Right, precedence+1)
+	}
+}
+
+// printOperand prints expr, in parentheses when its precedence is lower than precedence.
+func (p *printer) printOperand(expr Expression, precedence int) {
+	if expressionPrecedence(expr) < precedence {
+		p.write("(")
+		p.print(expr)
+		p.write(")")
+		return
+	}
+	p.print(expr)
+}
+
+// printMemberOperand prints the expression of a selector, element access or call. The parentheses
+// around an optional chain are kept, `(a?.b).c` fails when a is null while `a?.b.c` is null.
+func (p *printer) printMemberOperand(expr Expression) {
+	if paren, ok := expr.(*ParenthesizedExpression); ok && hasOptionalChain(skipParentheses(paren)) {
+		p.write("(")
+		p.print(paren)
+		p.write(")")
+		return
+	}
+	p.printOperand(expr, precedenceMember)
+}
+
+// hasOptionalChain reports whether expr is a chain of selectors, element accesses and calls with a `?.`.
+func hasOptionalChain(expr Expression) bool {
+	for {
+		switch n := expr.(type) {
+		case *SelectorExpression:
+			if n.Optional {
+				return true
+			}
+			expr = n.Expression
+		case *ElementAccessExpression:
+			if n.Optional {
+				return true
+			}
+			expr = n.Expression
+		case *CallExpression:
+			expr = n.Expression
+		default:
+			return false
+		}
+	}
+}
+
+func (p *printer) printLiteral(n *LiteralExpression) {
+	switch n.Token {
+	case SK_StringLiteral:
+		p.write(quoteString(n.Value))
+	case SK_NumberLiteral:
+		if n.Flags&TF_Percent != 0 {
+			p.write(n.Value + "%")
+			return
+		}
+		p.write(n.Value)
+	case SK_DateLiteral:
+		p.write("#" + n.Value + "#")
+	case SK_DurationLiteral:
+		p.write(n.Value)
+	default:
+		p.write(n.Token.ToString())
+	}
+}
+
+// printName prints an identifier, a name that can't be an identifier is quoted, e.g. `@'Order Total'`.
+// Keywords are names after `.` and in property assignments.
+func (p *printer) printName(value string, allowKeyword bool) {
+	if isIdentifierText(value) && (allowKeyword || KeywordFromString(value) == SK_Unknown) {
+		p.write(value)
+		return
+	}
+	p.write("@" + quoteString(value))
+}
+
+func expressionPrecedence(expr Expression) int {
+	switch n := expr.(type) {
+	case *ParenthesizedExpression:
+		return expressionPrecedence(n.Expression)
+	case *BinaryExpression:
+		if n.Operator.Token == SK_Comma {
+			return precedenceComma
+		}
+		if n.Operator.Token.IsAssignmentOperator() {
+			return precedenceAssignment
+		}
+		return getBinaryOperatorPrecedence(n.Operator.Token)
+	case *ConditionalExpression, *ArrowFunction, *SpreadElement:
+		return precedenceAssignment
+	case *BlockExpression:
+		return precedenceComma
+	case *PrefixUnaryExpression, *TypeOfExpression:
+		return precedenceUnary
+	case *CallExpression, *SelectorExpression, *ElementAccessExpression:
+		return precedenceMember
+	default:
+		return precedencePrimary
+	}
+}
+
+func skipParentheses(expr Expression) Expression {
+	for {
+		paren, ok := expr.(*ParenthesizedExpression)
+		if !ok {
+			return expr
+		}
+		expr = paren.Expression
+	}
+}
+
+func isIdentifierText(text string) bool {
+	for i, ch := range text {
+		if i == 0 && !IsIdentifierStart(ch) || i > 0 && !IsIdentifierPart(ch) {
+			return false
+		}
+	}
+	return len(text) > 0
+}
+
+// quoteString quotes s with `'`, the result is read back as s by the scanner.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, ch := range s {
+		switch ch {
+		case '\'':
+			b.WriteString(`\'`)
+		default:
+			writeEscapedRune(&b, ch)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// escapeTemplateText escapes the text of a template literal, `${` and '`' are escaped.
+func escapeTemplateText(s string) string {
+	var b strings.Builder
+	for i, ch := range s {
+		switch {
+		case ch == '`':
+			b.WriteString("\\`")
+		case ch == '$' && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteString(`\$`)
+		case ch == '\n':
+			b.WriteRune(ch)
+		default:
+			writeEscapedRune(&b, ch)
+		}
+	}
+	return b.String()
+}
+
+func writeEscapedRune(b *strings.Builder, ch rune) {
+	switch ch {
+	case '\\':
+		b.WriteString(`\\`)
+	case '\n':
+		b.WriteString(`\n`)
+	case '\r':
+		b.WriteString(`\r`)
+	case '\t':
+		b.WriteString(`\t`)
+	default:
+		if ch < ' ' || ch == 0x7f || ch == Uni_LineSeparator || ch == Uni_ParagraphSeparator {
+			fmt.Fprintf(b, `\u%04x`, ch)
+			return
+		}
+		b.WriteRune(ch)
+	}
 }
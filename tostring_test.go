@@ -0,0 +1,159 @@
+package formula
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	examples := map[string]string{
+		"a+(b*c)":                          "a + b * c",
+		"(a+b)*c":                          "(a + b) * c",
+		"a-(b-c)":                          "a - (b - c)",
+		"(a-b)-c":                          "a - b - c",
+		"((a))":                            "a",
+		"2**3**2":                          "2 ** 3 ** 2",
+		"(2**3)**2":                        "(2 ** 3) ** 2",
+		"(-2)**2":                          "(-2) ** 2",
+		"-(2**2)":                          "-(2 ** 2)",
+		"!(!a)":                            "! !a",
+		"x&&!!a":                           "x && !!a",
+		"-(-a)":                            "--a",
+		"typeof(a+b)":                      "typeof (a + b)",
+		"a?b:c?d:e":                        "a ? b : c ? d : e",
+		"(a?b:c)?d:e":                      "(a ? b : c) ? d : e",
+		"a||b&&c":                          "a || b && c",
+		"(a||b)&&c":                        "(a || b) && c",
+		"a not in [1,2]":                   "a not in [1, 2]",
+		"a in b":                           "a in b",
+		"x|>f(1)|>g":                       "x |> f(1) |> g",
+		"(x|>f)+1":                         "(x |> f) + 1",
+		"$a=$b=1":                          "$a = $b = 1",
+		"$a+=1,$a":                         "$a += 1, $a",
+		"f((a,b),c)":                       "f((a, b), c)",
+		"f(x=>x*2,(a,b)=>a+b)":             "f(x => x * 2, (a, b) => a + b)",
+		"(x=>x)(1)":                        "(x => x)(1)",
+		"a.b?.c!.d?.[0][1]":                "a.b?.c!.d?.[0][1]",
+		"(a+b).c":                          "(a + b).c",
+		"(1).toString":                     "(1).toString",
+		"{a:1,'b c':2,d,...e,true:3}":      "{ a: 1, 'b c': 2, d, ...e, true: 3 }",
+		"{}":                               "{}",
+		"[1,...a]":                         "[1, ...a]",
+		"f(arr...)":                        "f(...arr)",
+		"\"it's\"":                         `'it\'s'`,
+		"'a\\nb\\\\c'":                     `'a\nb\\c'`,
+		"`a${b}c${d+1}`":                   "`a${b}c${d + 1}`",
+		"`\\`\\${x}`":                      "`\\`\\${x}`",
+		"match(a){1,2=>'x',_=>'y'}":        "match (a) { 1, 2 => 'x', _ => 'y' }",
+		"match strict(a){_=>(b,c)}":        "match strict (a) { _ => (b, c) }",
+		"let a=1;let b=a*2\nb+1":           "let a = 1; let b = a * 2; b + 1",
+		"15%+1":                            "15 % +1",
+		"0x1F+1_000+1e3":                   "0x1f + 1000 + 1e3",
+		"#2024-05-01#+2h30m":               "#2024-05-01# + 2h30m",
+		"@'Order Total'+row.@'unit price'": "@'Order Total' + row.@'unit price'",
+		"@'true'":                          "@'true'",
+		"a.in":                             "a.in",
+		"(n?.b)!.c":                        "(n?.b)!.c",
+		"((n?.[0]))[1]":                    "(n?.[0])[1]",
+		"(n?.b.c)(1)":                      "(n?.b.c)(1)",
+		"(n.b)!.c+(n?.b)":                  "n.b!.c + n?.b",
+	}
+
+	for code, except := range examples {
+		formatted, err := Format([]byte(code))
+		if err != nil {
+			t.Errorf("%s %v", code, err)
+			return
+		}
+		if string(formatted) != except {
+			t.Errorf("format (%s) except: %s, but got: %s", code, except, formatted)
+			return
+		}
+		// the canonical form is stable
+		again, err := Format(formatted)
+		if err != nil || string(again) != except {
+			t.Errorf("format (%s) again except: %s, but got: %s %v", formatted, except, again, err)
+			return
+		}
+	}
+
+	percents := map[string]string{
+		"rate*15%":           "rate * 15%",
+		"(15%)+1":            "15% + 1",
+		"(15%)-1 > 0":        "15% - 1 > 0",
+		"match(a){(15%)=>1}": "match (a) { 15% => 1 }",
+		"10 % 3+15%%2":       "10 % 3 + 15% % 2",
+	}
+	for code, except := range percents {
+		formatted, err := Format([]byte(code), WithPercentLiterals())
+		if err != nil || string(formatted) != except {
+			t.Errorf("format (%s) except: %s, but got: %s %v", code, except, formatted, err)
+			return
+		}
+	}
+
+	for _, code := range []string{"a +", "// comment\na /* b */ + c"} {
+		if _, err := Format([]byte(code)); err == nil {
+			t.Errorf("%s except error", code)
+			return
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	codes := []string{
+		"1-(2-3)*4**(1/2)",
+		"-(2**2)+(-2)**2",
+		"(1+2)%3*15%",
+		"[1,2,3] |> map(x => x*(x-1)) |> join(',')",
+		"match (a+1) { 2, 3 => 'x', _ => `${a}%` }",
+		"let $t = (1, 2); $t += 3, $t",
+		"!(!a) && !!(b || c)",
+		"(n?.b)!.c",
+		"(n?.b)()",
+		"(n?.[0])!.x",
+	}
+
+	ctx := context.Background()
+	for _, code := range codes {
+		formatted, err := Format([]byte(code), WithPercentLiterals())
+		if err != nil {
+			t.Errorf("%s %v", code, err)
+			return
+		}
+		var values []interface{}
+		for _, text := range []string{code, string(formatted)} {
+			source, err := ParseSourceCode([]byte(text), WithPercentLiterals())
+			if err != nil {
+				t.Errorf("%s %v", text, err)
+				return
+			}
+			runner := NewRunner()
+			runner.SetThis(map[string]interface{}{"a": 1, "b": 0, "c": 2, "n": nil})
+			v, err := runner.Resolve(ctx, source.Expression)
+			// the formatted formula fails the same way
+			values = append(values, fmt.Sprint(v, err))
+		}
+		if values[0] != values[1] {
+			t.Errorf("%s except %v but got %v from %s", code, values[0], values[1], formatted)
+			return
+		}
+	}
+}
+
+func TestNullSelectorError(t *testing.T) {
+	source, err := ParseSourceCode([]byte("(order.items[0])!.price"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	runner := NewRunner()
+	runner.SetThis(map[string]interface{}{"order": map[string]interface{}{"items": []interface{}{nil}}})
+	_, err = runner.Resolve(context.Background(), source.Expression)
+	if err == nil || !strings.Contains(err.Error(), "expr order.items[0] value is null") {
+		t.Errorf("except null error but got %v", err)
+		return
+	}
+}
@@ -0,0 +1,164 @@
+package formula
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// scanTokens scans text into tokens that cover every byte of it. A fresh scanner is used so the
+// tokens don't depend on the lookahead of the parser.
+func scanTokens(text []byte, opts []ScanOption) []*Token {
+	var scanner = CreateScanner(text, nil, opts...)
+	var tokens []*Token
+	// Brace depth inside each open template, a `}` at depth 0 continues the template
+	var templateDepths []int
+	var depth int
+	var prevEnd int
+	for {
+		var kind = scanner.Scan()
+		switch kind {
+		case SK_TemplateHead:
+			templateDepths = append(templateDepths, depth)
+			depth = 0
+		case SK_OpenBrace:
+			depth++
+		case SK_CloseBrace:
+			if depth > 0 {
+				depth--
+			} else if len(templateDepths) > 0 {
+				kind = scanner.ReScanTemplateToken()
+				if kind == SK_TemplateTail {
+					depth = templateDepths[len(templateDepths)-1]
+					templateDepths = templateDepths[:len(templateDepths)-1]
+				}
+			}
+		}
+		var pos, end = scanner.GetTokenPos(), scanner.GetTextPos()
+		var token = &Token{Kind: kind, Text: string(text[pos:end])}
+		token.SetPos(pos)
+		token.SetEnd(end)
+		// The first token has no token before it, all of its trivia is leading
+		var split = prevEnd
+		if len(tokens) > 0 {
+			split = trailingTriviaEnd(text, prevEnd, pos)
+			tokens[len(tokens)-1].Trailing = string(text[prevEnd:split])
+		}
+		token.Leading = string(text[split:pos])
+		tokens = append(tokens, token)
+		prevEnd = end
+		if kind == SK_EndOfFile {
+			return tokens
+		}
+	}
+}
+
+// trailingTriviaEnd returns where the trailing trivia of the token ending at pos ends, that is after
+// the first line break, comments on the same line included. The trivia is never past end.
+func trailingTriviaEnd(text []byte, pos int, end int) int {
+	for pos < end {
+		ch, size := utf8.DecodeRune(text[pos:end])
+		if IsLineBreak(ch) {
+			if ch == '\r' && pos+1 < end && text[pos+1] == '\n' {
+				size++
+			}
+			return pos + size
+		}
+		if ch == '/' && pos+1 < end && text[pos+1] == '/' {
+			for pos += 2; pos < end; {
+				ch, size := utf8.DecodeRune(text[pos:end])
+				if IsLineBreak(ch) {
+					break
+				}
+				pos += size
+			}
+			continue
+		}
+		if ch == '/' && pos+1 < end && text[pos+1] == '*' {
+			if i := strings.Index(string(text[pos+2:end]), "*/"); i >= 0 {
+				pos += 2 + i + 2
+			} else {
+				pos = end
+			}
+			continue
+		}
+		if !IsWhiteSpace(ch) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// EmitSourceCode returns the text of the tokens with their trivia, for a source code parsed
+// WithTrivia it is the same as the parsed text byte for byte.
+func EmitSourceCode(source *SourceCode) []byte {
+	var b strings.Builder
+	for _, token := range source.Tokens {
+		b.WriteString(token.Leading)
+		b.WriteString(token.Text)
+		b.WriteString(token.Trailing)
+	}
+	return []byte(b.String())
+}
+
+// SpliceNode returns the source text with node replaced by replacement. The tokens outside of node
+// keep their trivia, the replacement is printed in the canonical style and put in parentheses when
+// its precedence is lower than the one of node. The source code must be parsed WithTrivia.
+func SpliceNode(source *SourceCode, node Node, replacement Node) ([]byte, error) {
+	if len(source.Tokens) == 0 {
+		return nil, fmt.Errorf("source code has no tokens, parse it with WithTrivia")
+	}
+	var start = SkipTrivia(source.Text, node.Pos())
+	var first, last = -1, -1
+	for i, token := range source.Tokens {
+		if token.Kind == SK_EndOfFile {
+			break
+		}
+		if token.Pos() >= start && token.Pos() < node.End() {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 || node.End() > len(source.Text) {
+		return nil, fmt.Errorf("node is not in the source code")
+	}
+
+	var p printer
+	if expr, ok := replacement.(Expression); ok && spliceNeedsParentheses(node, expr) {
+		p.write("(")
+		p.print(expr)
+		p.write(")")
+	} else {
+		p.print(replacement)
+	}
+
+	var b strings.Builder
+	for _, token := range source.Tokens[:first] {
+		b.WriteString(token.Leading)
+		b.WriteString(token.Text)
+		b.WriteString(token.Trailing)
+	}
+	b.WriteString(source.Tokens[first].Leading)
+	b.WriteString(p.buf.String())
+	b.WriteString(source.Tokens[last].Trailing)
+	for _, token := range source.Tokens[last+1:] {
+		b.WriteString(token.Leading)
+		b.WriteString(token.Text)
+		b.WriteString(token.Trailing)
+	}
+	return []byte(b.String()), nil
+}
+
+// spliceNeedsParentheses reports whether replacement needs parentheses in place of node.
+func spliceNeedsParentheses(node Node, replacement Expression) bool {
+	var precedence = precedencePrimary
+	if expr, ok := node.(Expression); ok {
+		if _, ok := expr.(*ParenthesizedExpression); !ok {
+			precedence = expressionPrecedence(expr)
+		}
+	}
+	return expressionPrecedence(replacement) < precedence
+}
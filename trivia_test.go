@@ -0,0 +1,129 @@
+package formula
+
+import (
+	"testing"
+)
+
+func TestEmitSourceCode(t *testing.T) {
+	examples := []string{
+		"",
+		"  ",
+		"a + b",
+		"  a+b  // sum\n",
+		"/* head */ f( 1 ,\r\n  2 ) // tail",
+		"let a = 1;\n\n// note\na * 2\n",
+		"`x ${ a + `y ${ {b: 1}.b }` } z`",
+		"match (x) {\n  1 => 'a',\n  _ => 'b'\n}",
+		"#2020-01-02# + 1d",
+		"@'Order Total' * 15%",
+		"f(1, /* unterminated",
+		"=let @'\r\n\n*-",
+	}
+	for _, text := range examples {
+		source := ParseSourceCodeWithDiagnostics([]byte(text), WithTrivia(), WithPercentLiterals())
+		if emitted := string(EmitSourceCode(source)); emitted != text {
+			t.Errorf("emit %q: got %q", text, emitted)
+		}
+	}
+
+	// The tokens are kept when the parser fails
+	text := "a + \\ // b"
+	source := ParseSourceCodeWithDiagnostics([]byte(text), WithTrivia(), panicOnScanError)
+	if emitted := string(EmitSourceCode(source)); emitted != text {
+		t.Errorf("emit %q after failure: got %q", text, emitted)
+	}
+}
+
+func TestTokenTrivia(t *testing.T) {
+	source, err := ParseSourceCode([]byte("a // one\n  + b"), WithTrivia())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := source.Tokens
+	if len(tokens) != 4 {
+		t.Fatalf("expect 4 tokens, got %d", len(tokens))
+	}
+	if tokens[0].Text != "a" || tokens[0].Trailing != " // one\n" {
+		t.Errorf("token a: got %q %q", tokens[0].Text, tokens[0].Trailing)
+	}
+	if tokens[1].Leading != "  " || tokens[1].Text != "+" || tokens[1].Trailing != " " {
+		t.Errorf("token +: got %q %q %q", tokens[1].Leading, tokens[1].Text, tokens[1].Trailing)
+	}
+	if tokens[3].Kind != SK_EndOfFile {
+		t.Errorf("expect last token to be end of file, got %v", tokens[3].Kind)
+	}
+}
+
+func TestSpliceNode(t *testing.T) {
+	examples := []struct {
+		text        string
+		replacement string
+		expect      string
+	}{
+		{"f( 1 ,  2 ) // keep", "x", "f( x ,  2 ) // keep"},
+		{"f( 1 ,  2 )", "a+b", "f( (a + b) ,  2 )"},
+		{"f( 1 ,  2 )", "(a,b)", "f( (a, b) ,  2 )"},
+		{"f(1)*2", "a", "f(a)*2"},
+		{"f(1)", "15%", "f(15%)"},
+		{"f(1 )+1", "15%", "f(15% )+1"},
+	}
+	for _, example := range examples {
+		source, err := ParseSourceCode([]byte(example.text), WithTrivia(), WithPercentLiterals())
+		if err != nil {
+			t.Fatal(err)
+		}
+		replacement, err := ParseSourceCode([]byte(example.replacement), WithPercentLiterals())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var call *CallExpression
+		if binary, ok := source.Expression.(*BinaryExpression); ok {
+			call = binary.Left.(*CallExpression)
+		} else {
+			call = source.Expression.(*CallExpression)
+		}
+		result, err := SpliceNode(source, call.Arguments.At(0), replacement.Expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result) != example.expect {
+			t.Errorf("splice %q into %q: expect %q, got %q", example.replacement, example.text, example.expect, string(result))
+		}
+	}
+}
+
+func TestSpliceNodePrecedence(t *testing.T) {
+	source, err := ParseSourceCode([]byte("a  *  b // product"), WithTrivia())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement, err := ParseSourceCode([]byte("c+d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := SpliceNode(source, source.Expression.(*BinaryExpression).Right, replacement.Expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "a  *  (c + d) // product" {
+		t.Errorf("got %q", string(result))
+	}
+	percent, err := ParseSourceCode([]byte("15%"), WithPercentLiterals())
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err = ParseSourceCode([]byte("x+1"), WithTrivia(), WithPercentLiterals())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = SpliceNode(source, source.Expression.(*BinaryExpression).Left, percent.Expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "15%+1" {
+		t.Errorf("got %q", string(result))
+	}
+	if _, err = SpliceNode(replacement, replacement.Expression, source.Expression); err == nil {
+		t.Errorf("expect error for source code without tokens")
+	}
+}
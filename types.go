@@ -33,50 +33,73 @@ const (
 	SK_Unknown SyntaxKind = iota
 	SK_EndOfFile
 
+	// Trivia
+	SK_SingleLineCommentTrivia
+	SK_MultiLineCommentTrivia
+
 	// Literal
 	SK_NumberLiteral
 	SK_StringLiteral
+	SK_DateLiteral     // #2024-05-01#
+	SK_DurationLiteral // 2h30m
+	SK_NoSubstitutionTemplateLiteral
+
+	// Pseudo-literals
+	SK_TemplateHead
+	SK_TemplateMiddle
+	SK_TemplateTail
 
 	// Punctuation
 	SK_OpenParen    // (
 	SK_CloseParen   // )
 	SK_OpenBracket  // [
 	SK_CloseBracket // ]
+	SK_OpenBrace    // {
+	SK_CloseBrace   // }
 	SK_Dot          // .
 	SK_DotDotDot    // ...
 	SK_Comma        // ,
-
-	SK_LessThan                // <
-	SK_GreaterThan             // >
-	SK_LessThanEquals          // <=
-	SK_GreaterThanEquals       // >=
-	SK_EqualsEquals            // ==
-	SK_EqualsEqualsEquals      // ===
-	SK_ExclamationEquals       // !=
-	SK_ExclamationEqualsEquals // !==
-	SK_Plus                    // +
-	SK_Minus                   // -
-	SK_Asterisk                // *
-	SK_Slash                   // /
-	SK_Percent                 // %
-	SK_Ampersand               // &
-	SK_Bar                     // |
-	SK_Caret                   // ^
-	SK_AmpersandAmpersand      // &&
-	SK_BarBar                  // ||
-	SK_QuestionQuestion        // ??
-	SK_Exclamation             // !
-	SK_ExclamationDot          // !.
-	SK_ExclamationExclamation  // !!
-	SK_Tilde                   // ~
-	SK_Question                // ?
-	SK_Colon                   // :
+	SK_Semicolon    // ;
+
+	SK_LessThan                          // <
+	SK_GreaterThan                       // >
+	SK_LessThanEquals                    // <=
+	SK_GreaterThanEquals                 // >=
+	SK_EqualsEquals                      // ==
+	SK_EqualsEqualsEquals                // ===
+	SK_ExclamationEquals                 // !=
+	SK_ExclamationEqualsEquals           // !==
+	SK_Plus                              // +
+	SK_Minus                             // -
+	SK_Asterisk                          // *
+	SK_Slash                             // /
+	SK_Percent                           // %
+	SK_AsteriskAsterisk                  // **
+	SK_LessThanLessThan                  // <<
+	SK_GreaterThanGreaterThan            // >>
+	SK_GreaterThanGreaterThanGreaterThan // >>>
+	SK_Ampersand                         // &
+	SK_Bar                               // |
+	SK_Caret                             // ^
+	SK_AmpersandAmpersand                // &&
+	SK_BarBar                            // ||
+	SK_QuestionQuestion                  // ??
+	SK_BarGreaterThan                    // |>
+	SK_Exclamation                       // !
+	SK_ExclamationDot                    // !.
+	SK_QuestionDot                       // ?.
+	SK_ExclamationExclamation            // !!
+	SK_Tilde                             // ~
+	SK_Question                          // ?
+	SK_Colon                             // :
+	SK_EqualsGreaterThan                 // =>
 
 	// Assignments
 	SK_Equals                                  // =
 	SK_PlusEquals                              // +=
 	SK_MinusEquals                             // -=
 	SK_AsteriskEquals                          // *=
+	SK_AsteriskAsteriskEquals                  // **=
 	SK_SlashEquals                             // /=
 	SK_PercentEquals                           // %=
 	SK_LessThanLessThanEquals                  // <<=
@@ -96,29 +119,85 @@ const (
 	SK_ThisKeyword
 	SK_CtxKeyword
 	SK_TypeofKeyword
+	SK_InKeyword
+	SK_NotKeyword
+	SK_LetKeyword
 
 	SK_Count
 	// Markers
 	SK_FirstAssignment     = SK_Equals
 	SK_LastAssignment      = SK_CaretEquals
 	SK_FirstKeyword        = SK_TrueKeyword
-	SK_LastKeyword         = SK_TypeofKeyword
+	SK_LastKeyword         = SK_LetKeyword
 	SK_FirstPunctuation    = SK_OpenParen
-	SK_LastPunctuation     = SK_Comma
+	SK_LastPunctuation     = SK_Semicolon
 	SK_FirstLiteral        = SK_NumberLiteral
-	SK_LastLiteral         = SK_StringLiteral
+	SK_LastLiteral         = SK_NoSubstitutionTemplateLiteral
+	SK_FirstTemplateToken  = SK_NoSubstitutionTemplateLiteral
+	SK_LastTemplateToken   = SK_TemplateTail
 	SK_FirstBinaryOperator = SK_LessThan
-	SK_LastBinaryOperator  = SK_QuestionQuestion
+	SK_LastBinaryOperator  = SK_BarGreaterThan
 )
 
 var tokens = [...]string{
 	// Punctuation
-	SK_OpenParen:    "(",
-	SK_CloseParen:   ")",
-	SK_OpenBracket:  "[",
-	SK_CloseBracket: "]",
-	SK_Dot:          ".",
-	SK_Comma:        ",",
+	SK_OpenParen:         "(",
+	SK_CloseParen:        ")",
+	SK_OpenBracket:       "[",
+	SK_CloseBracket:      "]",
+	SK_OpenBrace:         "{",
+	SK_CloseBrace:        "}",
+	SK_Dot:               ".",
+	SK_Comma:             ",",
+	SK_Semicolon:         ";",
+	SK_DotDotDot:         "...",
+	SK_Colon:             ":",
+	SK_EqualsGreaterThan: "=>",
+	// Operator
+	SK_LessThan:                          "<",
+	SK_GreaterThan:                       ">",
+	SK_LessThanEquals:                    "<=",
+	SK_GreaterThanEquals:                 ">=",
+	SK_EqualsEquals:                      "==",
+	SK_EqualsEqualsEquals:                "===",
+	SK_ExclamationEquals:                 "!=",
+	SK_ExclamationEqualsEquals:           "!==",
+	SK_Plus:                              "+",
+	SK_Minus:                             "-",
+	SK_Asterisk:                          "*",
+	SK_Slash:                             "/",
+	SK_Percent:                           "%",
+	SK_AsteriskAsterisk:                  "**",
+	SK_LessThanLessThan:                  "<<",
+	SK_GreaterThanGreaterThan:            ">>",
+	SK_GreaterThanGreaterThanGreaterThan: ">>>",
+	SK_Ampersand:                         "&",
+	SK_Bar:                               "|",
+	SK_Caret:                             "^",
+	SK_AmpersandAmpersand:                "&&",
+	SK_BarBar:                            "||",
+	SK_QuestionQuestion:                  "??",
+	SK_BarGreaterThan:                    "|>",
+	SK_Exclamation:                       "!",
+	SK_ExclamationDot:                    "!.",
+	SK_QuestionDot:                       "?.",
+	SK_ExclamationExclamation:            "!!",
+	SK_Tilde:                             "~",
+	SK_Question:                          "?",
+	// Assignment
+	SK_Equals:                       "=",
+	SK_PlusEquals:                   "+=",
+	SK_MinusEquals:                  "-=",
+	SK_AsteriskEquals:               "*=",
+	SK_AsteriskAsteriskEquals:       "**=",
+	SK_SlashEquals:                  "/=",
+	SK_PercentEquals:                "%=",
+	SK_LessThanLessThanEquals:       "<<=",
+	SK_GreaterThanGreaterThanEquals: ">>=",
+	SK_GreaterThanGreaterThanGreaterThanEquals: ">>>=",
+	SK_AmpersandEquals:                         "&=",
+	SK_BarEquals:                               "|=",
+	SK_CaretEquals:                             "^=",
 	// Keyword
 	SK_TrueKeyword:   "true",
 	SK_FalseKeyword:  "false",
@@ -126,6 +205,9 @@ var tokens = [...]string{
 	SK_ThisKeyword:   "this",
 	SK_CtxKeyword:    "ctx",
 	SK_TypeofKeyword: "typeof",
+	SK_InKeyword:     "in",
+	SK_NotKeyword:    "not",
+	SK_LetKeyword:    "let",
 }
 
 func (tok SyntaxKind) IsAssignmentOperator() bool {
@@ -270,6 +352,39 @@ type expression struct{ node }
 
 func (e expression) aExpression() {}
 
+type ObjectLiteralElement interface {
+	Node
+	aObjectLiteralElement()
+}
+
+type objectLiteralElement struct{ node }
+
+func (e objectLiteralElement) aObjectLiteralElement() {}
+
+type Statement interface {
+	Node
+	aStatement()
+}
+
+type statement struct{ node }
+
+func (s statement) aStatement() {}
+
+type (
+	// let Name = Initializer
+	VariableStatement struct {
+		Name        *Identifier
+		Initializer Expression
+		statement
+	}
+
+	// Expression;
+	ExpressionStatement struct {
+		Expression Expression
+		statement
+	}
+)
+
 type (
 	Identifier struct {
 		Value         string
@@ -313,6 +428,45 @@ type (
 		expression
 	}
 
+	// { name: x, 'total': a + b, y, ...base }
+	ObjectLiteralExpression struct {
+		Properties *NodeList[ObjectLiteralElement]
+		expression
+	}
+
+	// name: Initializer, 'name': Initializer
+	PropertyAssignment struct {
+		Name        Expression // *Identifier or *LiteralExpression
+		Initializer Expression
+		objectLiteralElement
+	}
+
+	// { name }
+	ShorthandPropertyAssignment struct {
+		Name *Identifier
+		objectLiteralElement
+	}
+
+	// { ...Expression }
+	SpreadAssignment struct {
+		Expression Expression
+		objectLiteralElement
+	}
+
+	// `Head${Expression}Literal${Expression}Literal`
+	TemplateExpression struct {
+		Head  string
+		Spans *NodeList[*TemplateSpan]
+		expression
+	}
+
+	// ${Expression}Literal
+	TemplateSpan struct {
+		Expression Expression
+		Literal    string
+		node
+	}
+
 	// (Expression)
 	ParenthesizedExpression struct {
 		Expression Expression
@@ -323,26 +477,87 @@ type (
 	LiteralExpression struct {
 		Token SyntaxKind
 		Value string
+		Flags TokenFlags
 		expression
 	}
 
-	// Expression.Name
+	// Expression.Name, Expression!.Name, Expression?.Name
 	SelectorExpression struct {
 		Expression Expression
 		Name       *Identifier
 		Assert     bool
+		Optional   bool
+		expression
+	}
+
+	// Expression[ArgumentExpression], Expression?.[ArgumentExpression]
+	ElementAccessExpression struct {
+		Expression         Expression
+		ArgumentExpression Expression
+		Optional           bool
+		expression
+	}
+
+	// x => Body, (a, b) => Body
+	ArrowFunction struct {
+		Parameters *NodeList[*Identifier]
+		Body       Expression
 		expression
 	}
 
 	// Expression(Arguments)
 	CallExpression struct {
-		Expression     Expression
-		Arguments      *NodeList[Expression]
-		DotDotDotToken *TokenNode
+		Expression Expression
+		Arguments  *NodeList[Expression]
+		expression
+	}
+
+	// ...Expression in array literals and argument lists, `f(arr...)` is the same as `f(...arr)`
+	SpreadElement struct {
+		Expression Expression
+		expression
+	}
+
+	// match [strict] (Expression) { 'a' => 1, 'b', 'c' => 2, _ => 0 }
+	MatchExpression struct {
+		Expression Expression
+		Strict     bool
+		Arms       *NodeList[*MatchArm]
+		expression
+	}
+
+	// Patterns => Result, Patterns is empty for the default arm `_`
+	MatchArm struct {
+		Patterns *NodeList[Expression]
+		Result   Expression
+		node
+	}
+
+	// Statements of a program, evaluates to the value of the last statement
+	BlockExpression struct {
+		Statements *NodeList[Statement]
 		expression
 	}
 )
 
+// CommentRange is a `// ...` or `/* ... */` comment, Kind is SK_SingleLineCommentTrivia or SK_MultiLineCommentTrivia.
+type CommentRange struct {
+	Kind SyntaxKind
+	Text string
+	textRange
+}
+
+// Token is a token with the trivia around it. Trailing holds the whitespace and comments after
+// the token up to the end of the line, Leading holds the rest before the token. The range is the
+// range of Text.
+type Token struct {
+	Kind     SyntaxKind
+	Leading  string
+	Text     string
+	Trailing string
+	textRange
+}
+
 type SourceCode struct {
 	Text []byte
 
@@ -352,7 +567,12 @@ type SourceCode struct {
 	IdentifierCount int
 	LineStarts      []int
 	Diagnostics     []*Diagnostic
-	Expression      Expression
+	Comments        []*CommentRange
+	Tokens          []*Token
+	Statements      *NodeList[Statement]
+	// Expression is the single expression statement of the program, or a
+	// BlockExpression holding all Statements
+	Expression Expression
 
 	node
 }
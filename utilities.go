@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 )
@@ -153,3 +154,12 @@ func isStringType(v any) bool {
 		return false
 	}
 }
+
+func isTimeType(v any) bool {
+	switch v.(type) {
+	case time.Time, time.Duration:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,175 @@
+package formula
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If the result visitor w
+// is not nil, Walk visits each of the children of node with the visitor w, followed by a call of
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, children are visited in source order. The token
+// nodes of operators are children too, e.g. the Operator of a BinaryExpression. For a *SourceCode
+// the children are the statements and the end of file token, its Expression is made from the
+// statements and is not visited.
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	forEachChild(node, func(child Node) {
+		Walk(child, v)
+	})
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order like Walk. It calls f(node) for each node, if f
+// returns true the children of node are inspected, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// Rewrite replaces the nodes of an AST in depth-first order, the children of a node are rewritten
+// before the node itself. f returns the node to put in place of its argument, or the argument to
+// keep it. The tree is changed in place and the parent links of the replacements are set, the
+// result is the replacement of node. Rewrite panics when a replacement doesn't fit in its place,
+// e.g. a CallExpression as the Name of a SelectorExpression.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if node == nil {
+		return nil
+	}
+	replaceChildren(node, func(child Node) Node {
+		return Rewrite(child, f)
+	})
+	// The expression of a single expression program is the one of its statement
+	if source, ok := node.(*SourceCode); ok && source.Statements.Len() == 1 {
+		if statement, ok := source.Statements.At(0).(*ExpressionStatement); ok {
+			source.Expression = statement.Expression
+		}
+	}
+	var result = f(node)
+	if result != nil && result != node {
+		result.SetParent(node.Parent())
+	}
+	return result
+}
+
+// forEachChild calls f for each child of node in source order.
+func forEachChild(node Node, f func(child Node)) {
+	replaceChildren(node, func(child Node) Node {
+		f(child)
+		return child
+	})
+}
+
+// setParentNodes sets the parent links of all nodes under root.
+func setParentNodes(root Node) {
+	forEachChild(root, func(child Node) {
+		child.SetParent(root)
+		setParentNodes(child)
+	})
+}
+
+// replaceChildren calls replace for each child of node in source order and puts the result in
+// place of the child.
+func replaceChildren(node Node, replace func(child Node) Node) {
+	switch n := node.(type) {
+	case *SourceCode:
+		replaceList(n, n.Statements, replace)
+		n.EndOfFileToken = replaceChild(n, n.EndOfFileToken, replace)
+	case *VariableStatement:
+		n.Name = replaceChild(n, n.Name, replace)
+		n.Initializer = replaceChild(n, n.Initializer, replace)
+	case *ExpressionStatement:
+		n.Expression = replaceChild(n, n.Expression, replace)
+	case *PrefixUnaryExpression:
+		n.Operator = replaceChild(n, n.Operator, replace)
+		n.Operand = replaceChild(n, n.Operand, replace)
+	case *TypeOfExpression:
+		n.Expression = replaceChild(n, n.Expression, replace)
+	case *BinaryExpression:
+		n.Left = replaceChild(n, n.Left, replace)
+		n.Operator = replaceChild(n, n.Operator, replace)
+		n.Right = replaceChild(n, n.Right, replace)
+	case *ConditionalExpression:
+		n.Condition = replaceChild(n, n.Condition, replace)
+		n.QuestionTok = replaceChild(n, n.QuestionTok, replace)
+		n.WhenTrue = replaceChild(n, n.WhenTrue, replace)
+		n.ColonTok = replaceChild(n, n.ColonTok, replace)
+		n.WhenFalse = replaceChild(n, n.WhenFalse, replace)
+	case *ArrayLiteralExpression:
+		replaceList(n, n.Elements, replace)
+	case *ObjectLiteralExpression:
+		replaceList(n, n.Properties, replace)
+	case *PropertyAssignment:
+		n.Name = replaceChild(n, n.Name, replace)
+		n.Initializer = replaceChild(n, n.Initializer, replace)
+	case *ShorthandPropertyAssignment:
+		n.Name = replaceChild(n, n.Name, replace)
+	case *SpreadAssignment:
+		n.Expression = replaceChild(n, n.Expression, replace)
+	case *TemplateExpression:
+		replaceList(n, n.Spans, replace)
+	case *TemplateSpan:
+		n.Expression = replaceChild(n, n.Expression, replace)
+	case *ParenthesizedExpression:
+		n.Expression = replaceChild(n, n.Expression, replace)
+	case *SelectorExpression:
+		n.Expression = replaceChild(n, n.Expression, replace)
+		n.Name = replaceChild(n, n.Name, replace)
+	case *ElementAccessExpression:
+		n.Expression = replaceChild(n, n.Expression, replace)
+		n.ArgumentExpression = replaceChild(n, n.ArgumentExpression, replace)
+	case *ArrowFunction:
+		replaceList(n, n.Parameters, replace)
+		n.Body = replaceChild(n, n.Body, replace)
+	case *CallExpression:
+		n.Expression = replaceChild(n, n.Expression, replace)
+		replaceList(n, n.Arguments, replace)
+	case *SpreadElement:
+		n.Expression = replaceChild(n, n.Expression, replace)
+	case *MatchExpression:
+		n.Expression = replaceChild(n, n.Expression, replace)
+		replaceList(n, n.Arms, replace)
+	case *MatchArm:
+		replaceList(n, n.Patterns, replace)
+		n.Result = replaceChild(n, n.Result, replace)
+	case *BlockExpression:
+		replaceList(n, n.Statements, replace)
+	case *Identifier, *LiteralExpression, *TokenNode:
+	default:
+		panic(fmt.Sprintf("unknown node type %T", node))
+	}
+}
+
+func replaceChild[T Node](parent Node, child T, replace func(child Node) Node) T {
+	var zero T
+	if any(child) == any(zero) {
+		return child
+	}
+	var result = replace(child)
+	if any(result) == any(child) {
+		return child
+	}
+	t, ok := result.(T)
+	if !ok {
+		panic(fmt.Sprintf("can't replace %T with %T in %T", child, result, parent))
+	}
+	t.SetParent(parent)
+	return t
+}
+
+func replaceList[T Node](parent Node, list *NodeList[T], replace func(child Node) Node) {
+	for i, child := range list.Array() {
+		list.nodes[i] = replaceChild(parent, child, replace)
+	}
+}
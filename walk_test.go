@@ -0,0 +1,171 @@
+package formula
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	source, err := ParseSourceCode([]byte("let $x = a + 1; f(b, {c: d, e}) ? `${g}` : h.i[j]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	Inspect(source, func(node Node) bool {
+		if identifier, ok := node.(*Identifier); ok {
+			names = append(names, identifier.Value)
+		}
+		return true
+	})
+	if strings.Join(names, " ") != "$x a f b c d e g h i j" {
+		t.Errorf("got %v", names)
+	}
+
+	// Children of a call are not inspected
+	names = nil
+	Inspect(source, func(node Node) bool {
+		if identifier, ok := node.(*Identifier); ok {
+			names = append(names, identifier.Value)
+		}
+		_, ok := node.(*CallExpression)
+		return !ok
+	})
+	if strings.Join(names, " ") != "$x a g h i j" {
+		t.Errorf("got %v", names)
+	}
+}
+
+type depthVisitor struct {
+	depth int
+	lines *[]string
+}
+
+func (v depthVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		*v.lines = append(*v.lines, strings.Repeat(" ", v.depth-1)+"end")
+		return nil
+	}
+	*v.lines = append(*v.lines, strings.Repeat(" ", v.depth)+fmt.Sprintf("%T", node))
+	return depthVisitor{depth: v.depth + 1, lines: v.lines}
+}
+
+func TestWalk(t *testing.T) {
+	source, err := ParseSourceCode([]byte("-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	Walk(source.Expression, depthVisitor{lines: &lines})
+	expect := []string{
+		"*formula.PrefixUnaryExpression",
+		" *formula.TokenNode",
+		" end",
+		" *formula.Identifier",
+		" end",
+		"end",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("got\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestParentNodes(t *testing.T) {
+	codes := []string{
+		"a.b(c + 1, [d, ...e])",
+		"let $x = 1; match ($x) { 1, 2 => `${a}`, _ => {b: c} }",
+		"x => y ? z : !w",
+	}
+	for _, code := range codes {
+		source, err := ParseSourceCode([]byte(code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parents []Node
+		Inspect(source, func(node Node) bool {
+			if node == nil {
+				parents = parents[:len(parents)-1]
+				return true
+			}
+			if len(parents) > 0 && node.Parent() != parents[len(parents)-1] {
+				t.Errorf("%s: wrong parent of %T %d", code, node, node.Pos())
+			}
+			parents = append(parents, node)
+			return true
+		})
+		if source.Expression.Parent() == nil {
+			t.Errorf("%s: program expression has no parent", code)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	source, err := ParseSourceCode([]byte("price * qty + order.price"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rename the field price, the selector name is not a field
+	Rewrite(source, func(node Node) Node {
+		if identifier, ok := node.(*Identifier); ok && identifier.Value == "price" {
+			if _, ok := identifier.Parent().(*SelectorExpression); !ok {
+				return &Identifier{Value: "unitPrice"}
+			}
+		}
+		return node
+	})
+	if got := astToString(source); got != "unitPrice * qty + order.price" {
+		t.Errorf("got %s", got)
+	}
+
+	// Replace a sub expression, the parents of the replacements are set
+	source, err = ParseSourceCode([]byte("a * b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement, err := ParseSourceCode([]byte("c + d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Rewrite(source, func(node Node) Node {
+		if identifier, ok := node.(*Identifier); ok && identifier.Value == "b" {
+			return replacement.Expression
+		}
+		return node
+	})
+	binary := source.Expression.(*BinaryExpression)
+	if got := astToString(source); got != "a * (c + d)" {
+		t.Errorf("got %s", got)
+	}
+	if binary.Right != replacement.Expression || binary.Right.Parent() != binary {
+		t.Errorf("replacement is not linked")
+	}
+
+	// The result of the root is returned
+	result := Rewrite(source.Expression, func(node Node) Node {
+		if _, ok := node.(*BinaryExpression); ok && node.Parent() != binary {
+			return &LiteralExpression{Token: SK_NumberLiteral, Value: "1"}
+		}
+		return node
+	})
+	if got := astToString(result); got != "1" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestRewritePanic(t *testing.T) {
+	source, err := ParseSourceCode([]byte("a.b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expect panic")
+		}
+	}()
+	Rewrite(source, func(node Node) Node {
+		if identifier, ok := node.(*Identifier); ok && identifier.Value == "b" {
+			return &LiteralExpression{Token: SK_NumberLiteral, Value: "1"}
+		}
+		return node
+	})
+}